@@ -5,9 +5,9 @@ package vv
 // Воркер - это обычная go-функция, но заточенная для работы в качестве goroutine.
 //          То есть, запускается при помощи команды go <имя_воркера>
 // id обработки - чтобы как-то идентифицировать каждый факт выполнения воркера (=goroutine),
-//          используется так называемый id обработки. Это тупое 64ричное целое число, которое постоянно формируется и
-//          (путем инкрементирования при каждом вызове) всегда наготове в канале VcPid.
-//          В начале кода каждого воркера мы должны запрашивать у канала VcPid свой id и далее ссылаться на этот id
+//          используется так называемый id обработки. Это тупое 64ричное целое число, которое выдает генератор
+//          id обработки (атомарный инкремент или время+узел+счетчик, см. vvlib11.go).
+//          В начале кода каждого воркера мы должны запрашивать через Getpid свой id и далее ссылаться на этот id
 //          где необходимо внутри кода воркера.
 // Логгер  - это специальная функция-воркер, которая записывает в текстовый файл (в дальнейшем это может быть база данных)
 //           основные идентификационные данные неких событий приложения. Каких именно - выбираем мы сами. Чтобы записать
@@ -20,17 +20,17 @@ package vv
 //           Пример вызова логгера: vv.Vlogger.Vlog(0, "Listener error:"+err.Error(), 1)
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
-	"errors"
 	"fmt"
-	"log"
 	"net/http"
-	"os"
-	"path/filepath"
 	"runtime"
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -40,10 +40,55 @@ import (
 // -----------------------------------------------------------------------------
 // общий лог событий приложения
 type Vlg struct {
-	Level int    // max уровень логирования для всего приложения:0-off, 1-errors, 2-errors+info
+	Level int    // max уровень логирования для всего приложения:0-off, 1-errors, 2-errors+warnings, 3-errors+warnings+info, 4-все+debug
 	File  string // полное имя файла для записи лога
+
+	Format string // формат строк лога: "" или "text" - текст фиксированной ширины, "json" - JSON Lines (см. vvlib6.go)
+
+	// ротация файла лога (см. vvlib4.go)
+	MaxSize  int64         // max размер файла лога, байт (0 - без ограничения)
+	Period   string        // ротация по календарю: "" - нет, "hourly" - каждый час, "daily" - каждые сутки
+	MaxFiles int           // сколько ротированных файлов хранить (0 - все)
+	MaxAge   time.Duration // max возраст ротированных файлов (0 - без ограничения)
+	Gzip     bool          // сжимать ротированные файлы в .gz
+
+	mu     sync.RWMutex   // защита levels и vc
+	cmu    sync.RWMutex   // отправка события в vc (RLock) против остановки лога в Shutdown (Lock)
+	levels map[string]int // переопределения уровня логирования для пакетов/функций
+	vc     chan Vle       // канал лога запущенной среды выполнения (nil - среда не запущена)
+	done   bool           // лог остановлен Shutdown: события без канала отбрасываются, а не пишутся в stderr
+	bp     int            // поведение при заполненном канале лога: BpBlock, BpDropNew, BpDropOld, BpSpill
+	spill  *vlspill       // файл сброса для BpSpill
+	cnt    vlcnt          // счетчики очереди лога (см. vvlib9.go)
+
+	smu    sync.Mutex   // защита приемников (см. vvlib5.go)
+	file   FileSink     // основной файл лога: приемник по умолчанию
+	sinks  []vsink      // дополнительные приемники лога
+	slevel atomic.Int32 // max уровень логирования дополнительных приемников
+
+	lerr atomic.Pointer[vlerr] // последняя ошибка записи в приемники (см. LastError)
 }
 
+// уровни логирования: значения Vlg.Level и переопределений SetLevel
+// - уровень 2 прежде означал ошибки + инфо, теперь это ошибки + предупреждения: для инфо задавайте Linf
+const (
+	Loff = 0 // логирование выключено
+	Lerr = 1 // только ошибки: Ftl, Err
+	Lwrn = 2 // ошибки + предупреждения: Ftl, Err, Wrn
+	Linf = 3 // ошибки + предупреждения + инфо: Ftl, Err, Wrn, Inf
+	Ldbg = 4 // все события, включая отладочные: Dbg
+)
+
+// типы событий: параметр etype метода Vlog
+// 0 и 1 сохранены для совместимости с прежними вызовами Vlog(pid, estr, 0|1)
+const (
+	Einf = 0 // информация
+	Eerr = 1 // ошибка
+	Ewrn = 2 // предупреждение
+	Edbg = 3 // отладка
+	Eftl = 4 // фатальная ошибка
+)
+
 // Vle событие приложения
 type Vle struct {
 	pid    uint64    // ключ: id обработки
@@ -55,106 +100,107 @@ type Vle struct {
 	gfile  string    // go-файл
 	funame string    // имя функции
 	line   int       // строка кода
-	etype  int       // тип события: 0-info, 1-error
+	etype  int       // тип события: Einf, Eerr, Ewrn, Edbg, Eftl
 }
 
-var Vlogger Vlg       // объект лога
-var VcLog chan Vle    // канал лога приложения
-var VcPid chan uint64 // канал раздачи id-обработки
-var vpid uint64       // хранение очередного номера id-обработки
-var Vapplt time.Time  // время запуска приложения
+var Vlogger Vlg      // объект лога
+var VcLog chan Vle   // канал лога приложения (среды выполнения по умолчанию Vrun)
+var Vapplt time.Time // время запуска приложения
+
+// VcPid канал раздачи id-обработки для прежнего кода, читающего id прямо из канала
+//
+// Deprecated: используйте Getpid. Канал питается от генератора id обработки (см. vvlib11.go) раздатчиком,
+// который стартует при старте среды выполнения Vrun; до Vrun.Start чтение из канала ждет.
+var VcPid = make(chan uint64)
 
 // -----------------------------------------------------------------------------
 // Функции пакета
 // init-----------------------------------------------------------------------------
 // Инициализация библиотеки
+// - воркеры (логгер, очиститель памяти) здесь не стартуют: их запускает Vrun.Start (vvlib8.go)
+// - до Vrun.Start события Vlogger пишутся прямо в стандартный поток ошибок, файл лога не создается
 func init() {
-	Vapplt = time.Now()             // время запуска приложения -> глобальная переменная приложения
-	go FreeMemory()                 // стартуем воркер периодического очистителя оперативной памяти
-	VcLog = make(chan Vle, 1000)    // создаем буферизованный (1000 элементов типа Vle) канал для логирования событий приложения
-	VcPid = make(chan uint64, 1000) // создаем буферизованный (1000 элементов типа uint64) канал для раздачи id-обработки (для идентификации воркеров)
-	vpid = 0                        // исходный id-обработки, будет инкрементироваться при каждом запросе
+	Vapplt = time.Now() // время запуска приложения -> глобальная переменная приложения
 
-	// задаем умолчания для имени log-файла и max уровня логирования
+	// задаем умолчания для имени log-файла и max уровня логирования (файл создается только при старте логгера)
 	if Vlogger.File == "" {
-		Vlogger.Level = 2
+		Vlogger.Level = Linf
 		Vlogger.File = "log.txt"
 	}
-
-	go gopid()                              // стартуем воркер раздатчика id-обработки
-	go logger()                             // стартуем воркер логгера
-	Vlogger.Vlog(0, "Application start", 0) // первая запись логгера при запуске приложения, id тупо ставим = 0
-	fmt.Print("Initialization completed\n") // выводим на консоль инфо-сообщение об успешном завершении инициализации приложения
-}
-
-// gopid-----------------------------------------------------------------------------
-// Воркер для формирования id-обработки
-// - постоянно пытается вытолкнуть в output-буфер канала очередной id-обработки
-// - логика формирования id-обработки: тупой инкремент для каждого нового id
-// - если output-буфер канала окажется полностью заполненным, то выполнение кода зависает на строке записи очередного id
-func gopid() {
-	for true {
-		vpid++
-		VcPid <- vpid // записываем в output-буфер канала очередной id-обработки
-	}
 }
 
 // Getpid -----------------------------------------------------------------------------
 // Получение id-обработки
 // - запрашивается только из кода воркеров
 // - если обработка ведется в основном потоке, то id всегда = 0
+// - id выдает текущий генератор id обработки (см. SetPidgen), без каналов и воркеров
 func Getpid() (pid uint64) {
-	pid = <-VcPid
+	pid = nextpid()
 	return pid
 }
 
 // FreeMemory -----------------------------------------------------------------------------
 // Воркер принудительного (каждые три секунды) запуска уборщика мусора
+// - в среде выполнения вместо него работает менеджер памяти, освобождающий память только выше порогов (см. vvlib15.go)
 func FreeMemory() {
+	freeMemory(context.Background())
+}
+
+// freeMemory -----------------------------------------------------------------------------
+// FreeMemory с остановкой по ctx
+func freeMemory(ctx context.Context) {
+	tick := time.NewTicker(3000 * time.Millisecond) // период выполнения
+	defer tick.Stop()
+
 	for true {
-		time.Sleep(3000 * time.Millisecond) // период выполнения
-		debug.FreeOSMemory()                // вызов уборщика мусора
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			debug.FreeOSMemory() // вызов уборщика мусора
+		}
 	}
 }
 
 // logger-----------------------------------------------------------------------------
-// Воркер логирования событий приложения в текстовый файл
-func logger() (err error) {
+// Воркер логирования событий приложения в приемники лога: основной текстовый файл и подключенные через AddSink
+func logger(ctx context.Context, vl *Vlg, vc chan Vle, quiet bool) (err error) {
 	// - логировать можно любые места в любом коде, если это не слишком затратно по времени
-	// - логировать можно и в файл, и в таблицу Logger базы данных
-	// - логировать нужно так, чтобы можно было выбирать
-	// - данные для логирования берем из глобального канала VcLog
+	// - логировать можно и в файл, и в таблицу Logger базы данных (DbSink), и в другие приемники
+	// - логировать нужно так, чтобы можно было выбирать: у каждого приемника свой уровень логирования
+	// - данные для логирования берем из канала лога среды выполнения (для Vlogger это глобальный канал VcLog)
 	// - эти данные засовывает в канал VcLog код в нужных местах при помощи функции Vlog,
 	// в которую передается текст конкретного сообщения и его тип: ошибка или инфо
+	// - по отмене ctx воркер дописывает всё, что осталось в канале, сбрасывает и закрывает приемники
 	Getpid() // забираем id обработки
-	fmt.Print("Logger: start\n")
-
-	// открываем файл лога
-	f, er := os.OpenFile(Vlogger.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if er != nil {
-		log.Println(er)
+	if !quiet {
+		fmt.Print("Logger: start\n")
 	}
 
+	tick := time.NewTicker(time.Second) // период сброса пакетных приемников
+	defer tick.Stop()
+
 	for true {
-		vle := <-VcLog // получаем очередное событие приложения для логирования
-
-		// формируем строку для добавления в файл лога
-		var fstr string = vle.etime.Format("2006-01-02 15:04:05.000") + " p" + // дата, время
-			RPads(strconv.FormatUint(vle.pid, 10), 8) + " " + // id обработки
-			Vifs(vle.etype == 0, "Inf", "Err") + " " + // тип события
-			RPads(filepath.Base(vle.gfile), 10) + " " + // имя go-файла
-			fmt.Sprintf("%5d", vle.line) + " " +
-			RPads(vle.funame, 15) + " " + // имя функции
-			vle.estr + "\r\n" // текст события
-
-		// записываем в log-файл
-		if _, er := f.WriteString(fstr); er != nil {
-			err = errors.New("Log file: write error: " + er.Error())
+		select {
+		case vle := <-vc: // получаем очередное событие приложения для логирования
+			vl.write(vle)
+		case <-tick.C:
+			vl.overflow()
+			if sp := vl.spill; sp != nil && len(vc) < cap(vc)/2 { // канал разгрузился - дочитываем сброшенное
+				sp.replay(vl)
+			}
+			vl.flush()
+		case <-ctx.Done():
+			for len(vc) > 0 {
+				vl.write(<-vc)
+			}
+			if sp := vl.spill; sp != nil {
+				sp.replay(vl)
+			}
+			vl.overflow()
+			return vl.close()
 		}
-
-		//fmt.Print("\nEvent has been writed");
 	}
-	defer f.Close()
 
 	return err
 }
@@ -184,122 +230,90 @@ func Vifs(result bool, v1 string, v2 string) (vv string) {
 // StatToByte -----------------------------------------------------------------------------
 // чтение файла в байт-массив
 func StatToByte(assets http.FileSystem, fname string) ([]byte, error) {
+	return StatToByteCtx(context.Background(), assets, fname)
+}
+
+// StatToByteCtx -----------------------------------------------------------------------------
+// чтение файла в байт-массив: id обработки берется из ctx (см. CtxWithPid)
+// - файл читается кусками до конца (см. StatCopy); недочитанный файл - ошибка, при успехе ошибка nil
+func StatToByteCtx(ctx context.Context, assets http.FileSystem, fname string) ([]byte, error) {
 	// input:
+	// ctx    - контекст обработки
 	// assets - файловая система в которой находится файл, который нужно поместить в http-ответ
 	// fname  - имя сохраненного статического файла
 	// output:
 	// []byte - массив байт файла
-	// error  - interface{} с ошибкой
+	// error  - ошибка открытия или чтения файла
 
-	var fil []byte
-	var err error
+	var buf bytes.Buffer
+	pid := GetpidCtx(ctx) // id обработки
 
-	pid := Getpid() // id обработки
+	start, status := time.Now(), vmStatusError // для метрик (см. vvlib16.go)
+	defer func() { vmStatic("StatToByte", status, buf.Len(), start) }()
 
-	err = errors.New("OK\n")
-
-	for true {
-		// открываем файл
-		file, er := assets.Open(fname)
-		if er != nil {
-			estr := "File open error"
-			Vlogger.Vlog(pid, estr, 1)
-			err = errors.New(estr)
-			break
-		}
-
-		// получаем длину файла
-		fstat, er := file.Stat()
-		if er != nil {
-			estr := "File stat getting error"
-			Vlogger.Vlog(pid, estr, 1)
-			err = errors.New(estr)
-			break
-		}
-
-		// читаем файл в буфер
-		fil = make([]byte, fstat.Size()) // создаем буфер для чтения файла: длина буфера = длина файла
-		nx, er := file.Read(fil)
-		if nx == 0 { // ошибка чтения файла
-			estr := "File read error"
-			Vlogger.Vlog(pid, estr, 1)
-			err = errors.New(estr)
-			break
-		}
-
-		defer file.Close() // безусловно закрываем файл в конце обработки
-		Vlogger.Vlog(pid, fname+" was red", 0)
-		break
+	if _, err := StatCopy(CtxWithPid(ctx, pid), &buf, assets, fname); err != nil {
+		return nil, err
 	}
 
-	return fil, err
+	Vlogger.Vlog(pid, fname+" was red", 0)
+	status = vmStatusOK
+	return buf.Bytes(), nil
 }
 
 // StatToHttp -----------------------------------------------------------------------------
 // web server: статический файл -> http-ответ
+// - без запроса: условные запросы и Range не учитываются, для них - StatToHttpReq (см. vvlib18.go)
 func StatToHttp(w http.ResponseWriter, assets http.FileSystem, fname string, ftype string) error {
+	return StatToHttpCtx(context.Background(), w, assets, fname, ftype)
+}
+
+// StatToHttpCtx -----------------------------------------------------------------------------
+// web server: статический файл -> http-ответ; id обработки берется из ctx (см. CtxWithPid)
+// - файл отдается потоком кусками (см. StatCopy), память не зависит от размера файла
+func StatToHttpCtx(ctx context.Context, w http.ResponseWriter, assets http.FileSystem, fname string, ftype string) error {
+	return statToHttp(ctx, w, assets, fname, ftype, true)
+}
+
+// statToHttp -----------------------------------------------------------------------------
+// StatToHttpCtx; sentLog - писать в лог отданный файл (Vstat сам пишет в лог каждый запрос, см. vvlib20.go)
+func statToHttp(ctx context.Context, w http.ResponseWriter, assets http.FileSystem, fname string, ftype string, sentLog bool) error {
 	// input:
+	// ctx    - контекст обработки, например r.Context()
 	// w      - объект для http-ответа
 	// assets - файловая система в которой находится файл, который нужно поместить в http-ответ
 	// fname  - имя файла
 	// ftype  - тип содержимого файла
+	// sentLog - писать ли в лог отданный файл
 	// output:
-	// error  - interface{} с ошибкой
+	// error  - ошибка открытия, чтения файла или записи в http-ответ; nil - файл отдан целиком
 
-	var err error
-	pid := Getpid() // id обработки
+	pid := GetpidCtx(ctx) // id обработки
 
-	for true {
-		// открываем файл
-		file, er := assets.Open(fname)
-		if er != nil {
-			estr := "File open error"
-			Vlogger.Vlog(pid, estr, 1)
-			errors.New(estr)
-			break
-		}
-
-		// получаем длину файла
-		fstat, er := file.Stat()
-		if er != nil {
-			estr := "File stat getting error"
-			Vlogger.Vlog(pid, estr, 1)
-			errors.New(estr)
-			break
-		}
-		//fmt.Println("fname:", fname  )
-
-		// создаем буфер для чтения всего файла
-		buf := make([]byte, fstat.Size())
-		nx, er := file.Read(buf)
-		if nx == 0 {
-			estr := "File read error"
-			Vlogger.Vlog(pid, estr, 1)
-			errors.New(estr)
-			break
-		}
+	start, status, sent := time.Now(), vmStatusError, int64(0) // для метрик (см. vvlib16.go)
+	defer func() { vmStatic("StatToHttp", status, int(sent), start) }()
 
-		if ftype != "" {
-			w.Header().Set("Content-Type", ftype)
-		} // утанавливаем тип файла
+	file, fstat, rd, err := statOpen(pid, assets, fname)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
 
-		// пишем содержимое файла в http-ответ
-		_, err = w.Write(buf)
-		if err != nil {
-			estr := "Sent to http error"
-			Vlogger.Vlog(pid, estr, 1)
-			errors.New(estr)
-			break
-		}
+	if ftype != "" {
+		w.Header().Set("Content-Type", ftype)
+	} // утанавливаем тип файла
+	w.Header().Set("Content-Length", strconv.FormatInt(fstat.Size(), 10))
 
-		// write the whole body at once
-		//err = ioutil.WriteFile("_"+fname, buf, 0644)
+	// пишем содержимое файла в http-ответ
+	sent, err = statCopy(ctx, pid, w, rd, fstat.Size(), fname)
+	if err != nil {
+		return err
+	}
 
-		defer file.Close() // закрываем файл в конце обработки
+	if sentLog {
 		Vlogger.Vlog(pid, fname+" was sent to http-reply", 0)
-		break
 	}
-	return err
+	status = vmStatusOK
+	return nil
 }
 
 // Vlog -----------------------------------------------------------------------------
@@ -308,24 +322,145 @@ func StatToHttp(w http.ResponseWriter, assets http.FileSystem, fname string, fty
 // Vlg Лог приложения
 // -----------------------------------------------------------------------------
 // запись строки в лог: вызывается из источника
+// - события, не проходящие по уровню логирования, отбрасываются здесь и в канал VcLog не попадают
 func (vl *Vlg) Vlog(pid uint64, estr string, etype int) {
 	// pid   - id обработки
 	// estr  - произвольная строка
-	// etype - тип события: 0-info, 1-error
+	// etype - тип события: Einf, Eerr, Ewrn, Edbg, Eftl
+	vl.vlog(2, pid, estr, etype, 0)
+}
 
-	pc, file, line, _ := runtime.Caller(1)
-	fn := runtime.FuncForPC(pc)
+// vlog -----------------------------------------------------------------------------
+// запись события в лог: общая часть Vlog и методов хода выполнения (vvlib7.go)
+// - depth - глубина стека до точки вызова, данные которой (файл, функция, строка) пишутся в событие
+// - имя процедуры и % выполнения берутся из реестра хода выполнения, если процедура pid выполняется
+func (vl *Vlg) vlog(depth int, pid uint64, estr string, etype int, event int) {
+	// без переопределений уровня решение принимаем до дорогого runtime.Caller;
+	// событие пропускается, если оно нужно файлу лога или хотя бы одному приемнику (их уровни проверяет write)
+	vl.mu.RLock()
+	nlv := len(vl.levels)
+	stopped := vl.vc == nil && vl.done
+	vl.mu.RUnlock()
+	slv := int(vl.slevel.Load())
+	if stopped || nlv == 0 && !Elevel(etype, max(vl.Level, slv)) {
+		return
+	}
+
+	pc, file, line, _ := runtime.Caller(depth)
+	var funame string
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		funame = fn.Name()
+	}
+
+	if nlv != 0 && !Elevel(etype, max(vl.LevelOf(funame), slv)) {
+		return
+	}
 
 	var vle Vle
 	vle.pid = pid
+	vle.event = event
 	vle.etime = time.Now()
 	vle.line = line
-	vle.funame = fn.Name()
+	vle.funame = funame
 	vle.estr = estr
 	vle.etype = etype
 	vle.gfile = file
+	if pr, ok := Progress(pid); ok && !pr.Done {
+		vle.pname = pr.Pname
+		vle.prc = pr.Prc
+	}
 
-	VcLog <- vle
+	// канал читаем под cmu: Shutdown не остановит лог, пока событие не отправлено,
+	// а после остановки событие сразу отбрасывается, а не теряется в уже дочитанном канале или не блокирует навсегда
+	vl.cmu.RLock()
+	defer vl.cmu.RUnlock()
+	vl.mu.RLock()
+	vc, bp, sp, done := vl.vc, vl.bp, vl.spill, vl.done
+	vl.mu.RUnlock()
+	if vc == nil {
+		// лог еще не запущен: приемников нет, событие по уровню основного лога пишется прямо в stderr
+		if !done && Elevel(etype, vl.LevelOf(funame)) {
+			StderrSink{Format: vl.Format}.Write(vle)
+		}
+		return
+	}
+	vl.send(vc, bp, sp, vle)
+}
+
+// SetLevel -----------------------------------------------------------------------------
+// переопределение уровня логирования для пакета или функции
+// - name: путь пакета ("github.com/user/app/worker"), функция ("main.worker") или метод ("main.(*Srv).Run")
+// - переопределение действует на саму функцию, её замыкания и на вложенные пакеты
+// - при нескольких совпадениях побеждает самое длинное имя
+func (vl *Vlg) SetLevel(name string, level int) {
+	vl.mu.Lock()
+	if vl.levels == nil {
+		vl.levels = make(map[string]int)
+	}
+	vl.levels[name] = level
+	vl.mu.Unlock()
+}
+
+// DelLevel -----------------------------------------------------------------------------
+// удаление переопределения уровня логирования для пакета или функции
+func (vl *Vlg) DelLevel(name string) {
+	vl.mu.Lock()
+	delete(vl.levels, name)
+	vl.mu.Unlock()
+}
+
+// LevelOf -----------------------------------------------------------------------------
+// уровень логирования, действующий для функции funame (полное имя, как в runtime.FuncForPC)
+func (vl *Vlg) LevelOf(funame string) int {
+	vl.mu.RLock()
+	defer vl.mu.RUnlock()
+
+	level, best := vl.Level, -1
+	for name, lv := range vl.levels {
+		if len(name) <= best || !strings.HasPrefix(funame, name) {
+			continue
+		}
+		// совпадение только по границе имени: "main.work" не должен задевать "main.worker"
+		if len(funame) > len(name) && funame[len(name)] != '.' && funame[len(name)] != '/' {
+			continue
+		}
+		level, best = lv, len(name)
+	}
+	return level
+}
+
+// Elevel -----------------------------------------------------------------------------
+// проходит ли событие типа etype при уровне логирования level
+func Elevel(etype int, level int) bool {
+	switch etype {
+	case Eftl, Eerr:
+		return level >= Lerr
+	case Ewrn:
+		return level >= Lwrn
+	case Einf:
+		return level >= Linf
+	case Edbg:
+		return level >= Ldbg
+	}
+	return level >= Lerr // неизвестный тип считаем ошибкой, чтобы не потерять событие
+}
+
+// Etname -----------------------------------------------------------------------------
+// краткое имя типа события для записи в лог
+func Etname(etype int) string {
+	switch etype {
+	case Einf:
+		return "Inf"
+	case Eerr:
+		return "Err"
+	case Ewrn:
+		return "Wrn"
+	case Edbg:
+		return "Dbg"
+	case Eftl:
+		return "Ftl"
+	}
+	return "E" + strconv.Itoa(etype)
 }
 
 // -----------------------------------------------------------------------------
@@ -391,11 +526,19 @@ func DbOpen(dbdescr string) error {
 // Qselect -----------------------------------------------------------------------------
 // sql select: текст
 func Qselect(qp Qp) (Qr, error) {
+	return QselectCtx(context.Background(), qp)
+}
+
+// QselectCtx -----------------------------------------------------------------------------
+// sql select: текст; id обработки берется из ctx (см. CtxWithPid), отмена ctx прерывает запрос
+func QselectCtx(ctx context.Context, qp Qp) (Qr, error) {
 	var qr Qr
-	pid := Getpid() // id обработки
+	pid := GetpidCtx(ctx) // id обработки
 
 	// выполняем запрос
-	rows, err := Dba.Query(qp.Qtxt)
+	start := time.Now() // для метрик (см. vvlib16.go)
+	rows, err := Dba.QueryContext(ctx, qp.Qtxt)
+	defer func() { vmSql("select", start, err) }()
 	if err != nil {
 		Vlogger.Vlog(pid, "DB query error: "+err.Error()+": "+qp.Qtxt, 1)
 		return qr, err
@@ -443,10 +586,16 @@ func Qselect(qp Qp) (Qr, error) {
 // Qrow -----------------------------------------------------------------------------
 // sql select row: текст
 func Qrow(qp Qp) (Qr1, error) {
+	return QrowCtx(context.Background(), qp)
+}
+
+// QrowCtx -----------------------------------------------------------------------------
+// sql select row: текст; id обработки берется из ctx (см. CtxWithPid)
+func QrowCtx(ctx context.Context, qp Qp) (Qr1, error) {
 
 	var qr1 Qr1
 	qp.Rmax = 1
-	qr, err := Qselect(qp)
+	qr, err := QselectCtx(ctx, qp)
 	if err != nil {
 		return qr1, err
 	}
@@ -462,13 +611,21 @@ func Qrow(qp Qp) (Qr1, error) {
 // Qexe -----------------------------------------------------------------------------
 // sql exe
 func Qexe(sql string) (Qrx, error) {
+	return QexeCtx(context.Background(), sql)
+}
 
-	pid := Getpid() // id обработки
+// QexeCtx -----------------------------------------------------------------------------
+// sql exe; id обработки берется из ctx (см. CtxWithPid), отмена ctx прерывает запрос
+func QexeCtx(ctx context.Context, sql string) (Qrx, error) {
+
+	pid := GetpidCtx(ctx) // id обработки
 
 	var qrx Qrx
 	var err error
 
-	res, err := Dba.Exec(sql)
+	start := time.Now() // для метрик (см. vvlib16.go)
+	res, err := Dba.ExecContext(ctx, sql)
+	vmSql("exec", start, err)
 
 	if err != nil {
 		Vlogger.Vlog(pid, "DB exec error: "+err.Error()+": "+sql, 1)
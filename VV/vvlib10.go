@@ -0,0 +1,57 @@
+package vv
+
+// id обработки в контексте:
+// - одна обработка (например, один http-запрос) должна логироваться под одним id,
+//   даже если она вызывает несколько функций пакета: StatToHttp, Qselect, Qexe и т.п.
+// - id кладется в context.Context через CtxWithPid, функции *Ctx берут его оттуда через GetpidCtx
+//   и запрашивают новый id через Getpid только если в контексте его нет
+// - PidHandler назначает id каждому http-запросу, кладет его в r.Context() и возвращает клиенту
+//   в заголовке X-Request-ID
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// vpidKey ключ id обработки в context.Context
+type vpidKey struct{}
+
+// CtxWithPid -----------------------------------------------------------------------------
+// контекст с id обработки pid
+func CtxWithPid(ctx context.Context, pid uint64) context.Context {
+	return context.WithValue(ctx, vpidKey{}, pid)
+}
+
+// PidFromCtx -----------------------------------------------------------------------------
+// id обработки из контекста, если он там есть
+func PidFromCtx(ctx context.Context) (uint64, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	pid, ok := ctx.Value(vpidKey{}).(uint64)
+	return pid, ok
+}
+
+// GetpidCtx -----------------------------------------------------------------------------
+// Getpid с контекстом: id обработки из контекста, а если его там нет - новый id обработки
+func GetpidCtx(ctx context.Context) uint64 {
+	if pid, ok := PidFromCtx(ctx); ok {
+		return pid
+	}
+	return Getpid()
+}
+
+// PidHandler -----------------------------------------------------------------------------
+// web server: middleware, назначающее каждому http-запросу свой id обработки
+// - если в контексте запроса id уже есть (например, от внешнего middleware), берется он, иначе - новый id
+// - id кладется в контекст запроса: обработчики берут его через GetpidCtx(r.Context())
+// - r.Context() нужно передавать в функции *Ctx, тогда они логируют под тем же id
+// - id возвращается клиенту в заголовке X-Request-ID
+func PidHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pid := GetpidCtx(r.Context()) // id обработки запроса
+		w.Header().Set("X-Request-ID", strconv.FormatUint(pid, 10))
+		next.ServeHTTP(w, r.WithContext(CtxWithPid(r.Context(), pid)))
+	})
+}
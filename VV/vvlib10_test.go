@@ -0,0 +1,70 @@
+package vv
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestPidCtx(t *testing.T) {
+	testPidgen(t, NewVidgen())
+
+	ctx := CtxWithPid(context.Background(), 42)
+	if pid, ok := PidFromCtx(ctx); !ok || pid != 42 {
+		t.Errorf("PidFromCtx = %d, %v, want 42", pid, ok)
+	}
+	if pid := GetpidCtx(ctx); pid != 42 {
+		t.Errorf("GetpidCtx with a pid in ctx = %d, want 42", pid)
+	}
+	if pid := GetpidCtx(CtxWithPid(ctx, 0)); pid != 0 { // 0 - тоже id: основной поток
+		t.Errorf("GetpidCtx with pid 0 in ctx = %d, want 0", pid)
+	}
+
+	// без id в контексте - новый id на каждый вызов
+	for _, ctx := range []context.Context{context.Background(), nil} {
+		if _, ok := PidFromCtx(ctx); ok {
+			t.Errorf("PidFromCtx(%v): want no pid", ctx)
+		}
+		a, b := GetpidCtx(ctx), GetpidCtx(ctx)
+		if a == 0 || a == b {
+			t.Errorf("GetpidCtx(%v) = %d, %d, want new distinct ids", ctx, a, b)
+		}
+	}
+}
+
+func TestPidHandler(t *testing.T) {
+	var got []uint64
+	h := PidHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// вложенные вызовы обработки берут тот же id из контекста запроса
+		got = append(got, GetpidCtx(r.Context()), GetpidCtx(CtxWithPid(r.Context(), GetpidCtx(r.Context()))))
+	}))
+
+	serve := func(r *http.Request) uint64 {
+		t.Helper()
+		got = nil
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		hdr, err := strconv.ParseUint(w.Header().Get("X-Request-ID"), 10, 64)
+		if err != nil {
+			t.Fatalf("X-Request-ID %q: %v", w.Header().Get("X-Request-ID"), err)
+		}
+		if len(got) != 2 || got[0] != hdr || got[1] != hdr {
+			t.Errorf("pids in the handler %v, want X-Request-ID %d", got, hdr)
+		}
+		return hdr
+	}
+
+	a := serve(httptest.NewRequest("GET", "/", nil))
+	b := serve(httptest.NewRequest("GET", "/", nil))
+	if a == b {
+		t.Errorf("two requests got the same pid %d", a)
+	}
+
+	// id, уже положенный в контекст внешним middleware, не заменяется
+	r := httptest.NewRequest("GET", "/", nil)
+	if pid := serve(r.WithContext(CtxWithPid(r.Context(), 777))); pid != 777 {
+		t.Errorf("request with a pid in ctx: X-Request-ID %d, want 777", pid)
+	}
+}
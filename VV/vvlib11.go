@@ -0,0 +1,145 @@
+package vv
+
+// Генератор id обработки:
+// - работает на атомарных операциях, без канала и воркера-раздатчика
+// - последовательный режим (NewVidgen, по умолчанию): 1, 2, 3, ... - как раньше, но после перезапуска снова с 1
+// - режим Snowflake (NewSnowgen): id = время в мс от 2024-01-01 (41 бит) | номер узла (10 бит) | счетчик (12 бит);
+//   id растут со временем, не повторяются после перезапуска и не пересекаются между экземплярами
+//   приложения с разными номерами узлов
+// - генератор для Getpid задается через SetPidgen или Vcfg.Pidgen при старте среды выполнения
+// - устаревший канал VcPid питается от того же генератора; его раздатчик запускает Vrun.Start (см. startPidfeed)
+//
+// Пример: vv.SetPidgen(vv.NewSnowgen(3)) // экземпляр приложения номер 3
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// поля id обработки в режиме Snowflake
+const (
+	snowEpoch    = 1704067200000              // начало отсчета: 2024-01-01 00:00:00 UTC, мс
+	snowNodeBits = 10                         // бит на номер узла
+	snowSeqBits  = 12                         // бит на счетчик внутри миллисекунды
+	snowNodeSh   = snowSeqBits                // сдвиг номера узла в id
+	snowTimeSh   = snowNodeBits + snowSeqBits // сдвиг времени в id
+	snowNodeMask = uint64(1<<snowNodeBits - 1)
+	snowSeqMask  = uint64(1<<snowSeqBits - 1)
+)
+
+// Vidgen генератор id обработки
+type Vidgen struct {
+	snow bool          // режим Snowflake
+	node uint64        // номер узла для режима Snowflake
+	last atomic.Uint64 // последний выданный id (последовательный режим) или мс<<12|счетчик (Snowflake)
+	n    atomic.Uint64 // выдано id
+}
+
+var vpidgen atomic.Pointer[Vidgen]    // генератор id обработки для Getpid
+var vpidfeed sync.Once                // однократный запуск раздатчика VcPid
+var vpidswap = make(chan struct{}, 1) // замена генератора: раздатчик VcPid бросает id, взятый из прежнего
+var vpidtotal atomic.Uint64           // выдано id для Getpid и VcPid за время работы процесса, по всем генераторам
+
+func init() {
+	vpidgen.Store(NewVidgen())
+}
+
+// NewVidgen -----------------------------------------------------------------------------
+// последовательный генератор id обработки: 1, 2, 3, ...
+func NewVidgen() *Vidgen {
+	return &Vidgen{}
+}
+
+// NewSnowgen -----------------------------------------------------------------------------
+// генератор id обработки в режиме Snowflake для узла node (0..1023)
+// - номер узла берется по модулю 1024
+func NewSnowgen(node int) *Vidgen {
+	return &Vidgen{snow: true, node: uint64(node) & snowNodeMask}
+}
+
+// SetPidgen -----------------------------------------------------------------------------
+// замена генератора id обработки, которым пользуется Getpid
+func SetPidgen(g *Vidgen) {
+	if g != nil {
+		vpidgen.Store(g)
+		select {
+		case vpidswap <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Next -----------------------------------------------------------------------------
+// очередной id обработки
+func (g *Vidgen) Next() uint64 {
+	g.n.Add(1)
+	if !g.snow {
+		return g.last.Add(1)
+	}
+
+	for {
+		old := g.last.Load()
+		oms, oseq := old>>snowSeqBits, old&snowSeqMask
+		ms := uint64(time.Now().UnixMilli() - snowEpoch)
+
+		var nw uint64
+		switch {
+		case ms > oms: // новая миллисекунда - счетчик с нуля
+			nw = ms << snowSeqBits
+		case oseq < snowSeqMask: // та же миллисекунда (или часы ушли назад) - продолжаем счетчик от последнего id
+			nw = old + 1
+		default: // счетчик миллисекунды исчерпан - занимаем следующую миллисекунду
+			nw = (oms + 1) << snowSeqBits
+		}
+
+		if g.last.CompareAndSwap(old, nw) {
+			return nw>>snowSeqBits<<snowTimeSh | g.node<<snowNodeSh | nw&snowSeqMask
+		}
+	}
+}
+
+// startPidfeed -----------------------------------------------------------------------------
+// запуск раздатчика id обработки в устаревший канал VcPid (только из Vrun.Start, для прежнего кода)
+// - канал без буфера: заранее взят не больше одного id; пока его не прочли, он не учтен в vv_pids_total
+// - последовательность Getpid пропускает взятый раздатчиком id, после SetPidgen он берет id из нового генератора
+func startPidfeed() {
+	go func() {
+		for {
+			id := vpidgen.Load().Next()
+			select {
+			case <-vpidswap:
+				continue
+			default:
+			}
+			select {
+			case VcPid <- id:
+				vpidtotal.Add(1)
+			case <-vpidswap:
+			}
+		}
+	}()
+}
+
+// nextpid -----------------------------------------------------------------------------
+// следующий id обработки текущего генератора с учетом в общем счетчике процесса (метрика vv_pids_total)
+// - счетчик генератора (Count) начинается заново при замене генератора, общий - нет
+func nextpid() uint64 {
+	vpidtotal.Add(1)
+	return vpidgen.Load().Next()
+}
+
+// Count -----------------------------------------------------------------------------
+// сколько id выдано генератором
+func (g *Vidgen) Count() uint64 {
+	return g.n.Load()
+}
+
+// Snowid -----------------------------------------------------------------------------
+// разбор id обработки режима Snowflake: время выдачи, номер узла и счетчик
+func Snowid(pid uint64) (t time.Time, node int, seq int) {
+	t = time.UnixMilli(int64(pid>>snowTimeSh) + snowEpoch)
+	node = int(pid >> snowNodeSh & snowNodeMask)
+	seq = int(pid & snowSeqMask)
+	return t, node, seq
+}
@@ -0,0 +1,138 @@
+package vv
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// testPidgen генератор для Getpid на время теста
+func testPidgen(t *testing.T, g *Vidgen) {
+	prev := vpidgen.Load()
+	SetPidgen(g)
+	t.Cleanup(func() { SetPidgen(prev) })
+}
+
+func TestVidgenSeq(t *testing.T) {
+	g := NewVidgen()
+	for want := uint64(1); want <= 3; want++ {
+		if got := g.Next(); got != want {
+			t.Errorf("Next = %d, want %d", got, want)
+		}
+	}
+	if g.Count() != 3 {
+		t.Errorf("Count = %d, want 3", g.Count())
+	}
+}
+
+func TestVidgenConcurrent(t *testing.T) {
+	for _, g := range []*Vidgen{NewVidgen(), NewSnowgen(5)} {
+		const workers, per = 8, 5000 // больше 4096 id за миллисекунду: Snowflake занимает следующие
+		ids := make(chan uint64, workers*per)
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < per; j++ {
+					ids <- g.Next()
+				}
+			}()
+		}
+		wg.Wait()
+		close(ids)
+
+		seen := make(map[uint64]bool, workers*per)
+		for id := range ids {
+			if seen[id] {
+				t.Fatalf("snow=%v: duplicate id %d", g.snow, id)
+			}
+			seen[id] = true
+		}
+	}
+}
+
+func TestSnowgen(t *testing.T) {
+	g := NewSnowgen(1024 + 7) // номер узла по модулю 1024
+	before := time.Now().UnixMilli()
+	prev := uint64(0)
+	for i := 0; i < 10000; i++ {
+		id := g.Next()
+		if id <= prev {
+			t.Fatalf("id %d after %d: want growing ids", id, prev)
+		}
+		prev = id
+
+		if node := id >> snowNodeSh & snowNodeMask; node != 7 {
+			t.Fatalf("node = %d, want 7", node)
+		}
+	}
+	if ms := int64(g.Next()>>snowTimeSh) + snowEpoch; ms < before {
+		t.Errorf("id time %d before generation start %d", ms, before)
+	}
+}
+
+func TestGetpidSeq(t *testing.T) {
+	testPidgen(t, NewVidgen())
+	before := vpidtotal.Load()
+
+	// раздатчик VcPid, если он запущен, держит свой id, но пока его не прочли, Getpid идут подряд и учтены точно
+	prev := Getpid()
+	for i := 0; i < 5; i++ {
+		id := Getpid()
+		if id != prev+1 {
+			t.Fatalf("Getpid = %d after %d, want contiguous ids", id, prev)
+		}
+		prev = id
+	}
+	if got := vpidtotal.Load() - before; got != 6 {
+		t.Errorf("pids counted = %d, want 6", got)
+	}
+}
+
+func TestVcPid(t *testing.T) {
+	vpidfeed.Do(startPidfeed) // так раздатчик запускает Vrun.Start
+	testPidgen(t, NewVidgen())
+
+	seen := make(map[uint64]bool)
+	for i := 0; i < 10; i++ {
+		var id uint64
+		if i%2 == 0 {
+			id = Getpid()
+		} else {
+			select {
+			case id = <-VcPid:
+			case <-time.After(time.Second):
+				t.Fatal("VcPid is not fed")
+			}
+		}
+		if seen[id] {
+			t.Fatalf("duplicate id %d from Getpid and VcPid", id)
+		}
+		seen[id] = true
+	}
+
+	// после замены генератора раздатчик не отдает id, взятый из прежнего
+	testPidgen(t, NewSnowgen(5))
+	for i := 0; i < 3; i++ {
+		if _, node, _ := Snowid(<-VcPid); node != 5 {
+			t.Fatalf("VcPid after SetPidgen: node %d, want an id of the new generator", node)
+		}
+	}
+}
+
+func TestPidsTotal(t *testing.T) {
+	testPidgen(t, NewVidgen())
+	before := vpidtotal.Load()
+	Getpid()
+	Getpid()
+
+	SetPidgen(NewSnowgen(1)) // счетчик генератора начинается заново, общий - нет
+	Getpid()
+	if got := vpidtotal.Load() - before; got != 3 {
+		t.Errorf("pids issued across SetPidgen: %d, want 3", got)
+	}
+	if got := vpidgen.Load().Count(); got > 2 { // раздатчик VcPid мог взять свой id, не выдав его
+		t.Errorf("Count of the new generator = %d, want at most 2", got)
+	}
+}
@@ -0,0 +1,273 @@
+package vv
+
+// Супервизор воркеров:
+// - воркер запускается не голой командой go, а через Vsup.Go под своим именем и id обработки
+// - паника воркера перехватывается и пишется вместе со стеком вызовов (тип события Eftl) мимо канала лога:
+//   в стандартный поток ошибок и в приемники, - упавшим может быть сам логгер при заполненном канале
+// - завершившийся воркер перезапускается по политике: RsNever - никогда, RsAlways - всегда через паузу Min,
+//   RsBackoff - всегда, но пауза растет вдвое после каждого перезапуска от Min до Max
+// - воркер получает ctx с id обработки (GetpidCtx) и должен завершаться по ctx.Done(): так работают Stop и StopAll
+// - Workers возвращает список воркеров с временем старта, состоянием и числом перезапусков
+// Vsuper - супервизор по умолчанию; у каждой среды выполнения Vrt свой супервизор для логгера и очистителя памяти.
+//
+// Пример:
+//   vv.Vsuper.Go("importer", vv.Vrsp{Mode: vv.RsBackoff}, func(ctx context.Context, pid uint64) error {
+//       ...
+//   })
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// политики перезапуска воркера
+const (
+	RsNever   = 0 // не перезапускать
+	RsAlways  = 1 // перезапускать через паузу Min
+	RsBackoff = 2 // перезапускать с паузой, растущей от Min до Max
+)
+
+// состояния воркера
+const (
+	WsRunning    = "running"    // работает
+	WsRestarting = "restarting" // ждет перезапуска
+	WsStopped    = "stopped"    // остановлен через ctx
+	WsDone       = "done"       // завершился без ошибки и не перезапускается
+	WsFailed     = "failed"     // завершился с ошибкой или паникой и не перезапускается
+)
+
+// Vwfn функция воркера
+type Vwfn func(ctx context.Context, pid uint64) error
+
+// Vrsp политика перезапуска воркера
+type Vrsp struct {
+	Mode int           // RsNever, RsAlways, RsBackoff
+	Min  time.Duration // пауза перед перезапуском (RsBackoff - начальная), по умолчанию 1 сек
+	Max  time.Duration // max пауза для RsBackoff, по умолчанию 1 мин; проработавший дольше Max воркер сбрасывает паузу к Min
+}
+
+// Vwrk состояние воркера
+type Vwrk struct {
+	Name     string    // имя воркера
+	Pid      uint64    // id обработки
+	Start    time.Time // время последнего (пере)запуска
+	Status   string    // состояние: WsRunning, WsRestarting, WsStopped, WsDone, WsFailed
+	Restarts int       // число перезапусков
+	Err      string    // последняя ошибка или паника
+}
+
+// Vsup супервизор воркеров; нулевое значение готово к работе
+type Vsup struct {
+	Log *Vlg // лог супервизора (nil - Vlogger)
+
+	mu  sync.Mutex
+	wks map[string]*vwrk // воркеры по именам
+}
+
+// vwrk воркер под надзором
+type vwrk struct {
+	st     Vwrk               // состояние (под Vsup.mu)
+	cancel context.CancelFunc // остановка воркера
+	done   chan struct{}      // закрывается, когда цикл надзора завершился
+}
+
+// Vsuper супервизор воркеров по умолчанию
+var Vsuper Vsup
+
+// Go -----------------------------------------------------------------------------
+// запуск воркера fn под именем name с политикой перезапуска rsp; возвращает id обработки воркера
+// - имя должно быть уникальным среди работающих воркеров; завершившегося воркера можно запустить заново
+func (sv *Vsup) Go(name string, rsp Vrsp, fn Vwfn) (uint64, error) {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+
+	if w, ok := sv.wks[name]; ok && (w.st.Status == WsRunning || w.st.Status == WsRestarting) {
+		return 0, errors.New("Worker is already running: " + name)
+	}
+	if sv.wks == nil {
+		sv.wks = make(map[string]*vwrk)
+	}
+
+	if rsp.Min <= 0 {
+		rsp.Min = time.Second
+	}
+	if rsp.Max < rsp.Min {
+		rsp.Max = time.Minute
+		if rsp.Max < rsp.Min {
+			rsp.Max = rsp.Min
+		}
+	}
+
+	pid := Getpid() // id обработки воркера: один на все перезапуски
+	ctx, cancel := context.WithCancel(CtxWithPid(context.Background(), pid))
+	w := &vwrk{st: Vwrk{Name: name, Pid: pid, Start: time.Now(), Status: WsRunning}, cancel: cancel, done: make(chan struct{})}
+	sv.wks[name] = w
+
+	go sv.run(ctx, w, rsp, fn)
+	return pid, nil
+}
+
+// run -----------------------------------------------------------------------------
+// цикл надзора за воркером: запуск, перехват паники, перезапуск по политике
+func (sv *Vsup) run(ctx context.Context, w *vwrk, rsp Vrsp, fn Vwfn) {
+	defer close(w.done)
+	defer w.cancel()
+
+	vl := sv.log()
+	name, pid := w.st.Name, w.st.Pid
+	delay := rsp.Min
+
+	for {
+		start := time.Now()
+		sv.set(w, func(st *Vwrk) { st.Status, st.Start = WsRunning, start })
+
+		err := sv.call(ctx, vl, name, pid, fn)
+
+		if ctx.Err() != nil { // остановлен через Stop/StopAll
+			sv.set(w, func(st *Vwrk) { st.Status = WsStopped })
+			vl.Vlog(pid, "Worker "+name+": stopped", Einf)
+			return
+		}
+
+		var pe *vpanic
+		panicked := errors.As(err, &pe)
+		if err != nil {
+			sv.set(w, func(st *Vwrk) { st.Err = err.Error() })
+			if !panicked { // о панике call уже сообщил
+				vl.Vlog(pid, "Worker "+name+": "+err.Error(), Eerr)
+			}
+		}
+
+		if rsp.Mode != RsAlways && rsp.Mode != RsBackoff {
+			sv.set(w, func(st *Vwrk) { st.Status = Vifs(err == nil, WsDone, WsFailed) })
+			return
+		}
+
+		if rsp.Mode == RsBackoff && time.Since(start) > rsp.Max { // долго проработал - начинаем паузы сначала
+			delay = rsp.Min
+		}
+
+		sv.set(w, func(st *Vwrk) { st.Status = WsRestarting; st.Restarts++ })
+		if panicked { // упавшим мог быть логгер: сообщение мимо канала, чтобы не ждать его перезапуска
+			vl.direct(pid, "Worker "+name+": restart in "+delay.String(), Ewrn)
+		} else {
+			vl.Vlog(pid, "Worker "+name+": restart in "+delay.String(), Ewrn)
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			sv.set(w, func(st *Vwrk) { st.Status = WsStopped })
+			return
+		case <-t.C:
+		}
+
+		if rsp.Mode == RsBackoff {
+			delay *= 2
+			if delay > rsp.Max {
+				delay = rsp.Max
+			}
+		}
+	}
+}
+
+// vpanic паника воркера, перехваченная call
+type vpanic struct {
+	r interface{} // значение паники
+}
+
+func (p *vpanic) Error() string { return fmt.Sprintf("panic: %v", p.r) }
+
+// call -----------------------------------------------------------------------------
+// один запуск воркера с перехватом паники
+// - отчет о панике пишется через direct, а не Vlog: см. описание файла
+func (sv *Vsup) call(ctx context.Context, vl *Vlg, name string, pid uint64, fn Vwfn) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &vpanic{r: r}
+			vl.direct(pid, "Worker "+name+": panic: "+fmt.Sprint(r)+"\n"+string(debug.Stack()), Eftl)
+		}
+	}()
+	return fn(ctx, pid)
+}
+
+// set -----------------------------------------------------------------------------
+// изменение состояния воркера под блокировкой
+func (sv *Vsup) set(w *vwrk, fn func(st *Vwrk)) {
+	sv.mu.Lock()
+	fn(&w.st)
+	sv.mu.Unlock()
+}
+
+// log -----------------------------------------------------------------------------
+func (sv *Vsup) log() *Vlg {
+	if sv.Log != nil {
+		return sv.Log
+	}
+	return &Vlogger
+}
+
+// Stop -----------------------------------------------------------------------------
+// остановка воркера name через отмену его ctx (не дожидаясь завершения)
+func (sv *Vsup) Stop(name string) error {
+	sv.mu.Lock()
+	w, ok := sv.wks[name]
+	sv.mu.Unlock()
+
+	if !ok {
+		return errors.New("Worker not found: " + name)
+	}
+	w.cancel()
+	return nil
+}
+
+// StopAll -----------------------------------------------------------------------------
+// остановка всех воркеров с ожиданием их завершения
+// - если ctx завершится раньше воркеров, возвращается ошибка ctx
+func (sv *Vsup) StopAll(ctx context.Context) error {
+	sv.mu.Lock()
+	wks := make([]*vwrk, 0, len(sv.wks))
+	for _, w := range sv.wks {
+		w.cancel()
+		wks = append(wks, w)
+	}
+	sv.mu.Unlock()
+
+	for _, w := range wks {
+		select {
+		case <-w.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// running -----------------------------------------------------------------------------
+// работает ли воркер name (или ждет перезапуска)
+func (sv *Vsup) running(name string) bool {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	w, ok := sv.wks[name]
+	return ok && (w.st.Status == WsRunning || w.st.Status == WsRestarting)
+}
+
+// Workers -----------------------------------------------------------------------------
+// список воркеров по именам
+func (sv *Vsup) Workers() []Vwrk {
+	sv.mu.Lock()
+	wks := make([]Vwrk, 0, len(sv.wks))
+	for _, w := range sv.wks {
+		wks = append(wks, w.st)
+	}
+	sv.mu.Unlock()
+
+	sort.Slice(wks, func(i, j int) bool { return wks[i].Name < wks[j].Name })
+	return wks
+}
@@ -0,0 +1,130 @@
+package vv
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testPanicSink приемник лога, паникующий на первой записи
+type testPanicSink struct {
+	done atomic.Bool
+}
+
+func (ps *testPanicSink) Write(vle Vle) error {
+	if ps.done.CompareAndSwap(false, true) {
+		panic("sink is broken")
+	}
+	return nil
+}
+func (ps *testPanicSink) Flush() error { return nil }
+func (ps *testPanicSink) Close() error { return nil }
+
+// testStderr перенаправление стандартного потока ошибок в файл на время теста
+func testStderr(t *testing.T) string {
+	name := filepath.Join(t.TempDir(), "stderr")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = f
+	t.Cleanup(func() {
+		os.Stderr = stderr
+		f.Close()
+	})
+	return name
+}
+
+func TestSupervisorRestart(t *testing.T) {
+	testStderr(t)
+	sv := &Vsup{Log: &Vlg{}}
+
+	var calls atomic.Int32
+	sv.Go("flaky", Vrsp{Mode: RsAlways, Min: time.Millisecond}, func(ctx context.Context, pid uint64) error {
+		switch calls.Add(1) {
+		case 1:
+			return errors.New("first failure")
+		case 2:
+			panic("second failure")
+		}
+		<-ctx.Done()
+		return nil
+	})
+	if _, err := sv.Go("flaky", Vrsp{}, nil); err == nil {
+		t.Error("Go with a running name: want error")
+	}
+
+	for end := time.Now().Add(5 * time.Second); calls.Load() < 3; time.Sleep(time.Millisecond) {
+		if time.Now().After(end) {
+			t.Fatal("worker was not restarted")
+		}
+	}
+	ws := sv.Workers()
+	if len(ws) != 1 || ws[0].Restarts != 2 || ws[0].Err != "panic: second failure" {
+		t.Errorf("Workers = %+v, want 2 restarts after a panic", ws)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sv.StopAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ws := sv.Workers(); ws[0].Status != WsStopped {
+		t.Errorf("Status after StopAll = %s, want %s", ws[0].Status, WsStopped)
+	}
+}
+
+func TestSupervisorLoggerPanic(t *testing.T) {
+	stderr := testStderr(t)
+
+	rt := NewVrt()
+	ring := NewRingSink(1000)
+	rt.Log.AddSink("broken", &testPanicSink{}, Ldbg)
+	rt.Log.AddSink("ring", ring, Ldbg)
+	if err := rt.Start(Vcfg{Quiet: true, QueueSize: 1, Backpressure: BpBlock}); err != nil {
+		t.Fatal(err)
+	}
+
+	// логгер падает на первом событии, канал тут же заполняется: отчет о панике не должен ждать места в канале
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rt.Log.Vlog(1, "event", Einf)
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Vlog blocked: the panicked logger was not restarted")
+	}
+	if err := rt.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var fatal bool
+	for _, vle := range ring.Events() {
+		if vle.Etype() == Eftl && strings.Contains(vle.Estr(), "sink is broken") {
+			fatal = true
+		}
+	}
+	if !fatal {
+		t.Error("panic report was not written to the sinks")
+	}
+	if b, _ := os.ReadFile(stderr); !strings.Contains(string(b), "Worker vv.logger: panic: sink is broken") {
+		t.Errorf("panic report was not written to stderr: %q", b)
+	}
+}
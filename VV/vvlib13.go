@@ -0,0 +1,238 @@
+package vv
+
+// Пул воркеров:
+// - фиксированное число воркеров и ограниченная очередь заданий вместо неограниченного запуска go worker()
+// - Submit не блокируется: при заполненной очереди сразу возвращает ErrQueueFull
+// - каждое задание получает свой id обработки (в ctx задания, см. GetpidCtx), результат и ошибку
+//   вызывающий код забирает через Vjob.Wait
+// - старт, окончание и длительность каждого задания пишутся в лог; паника задания перехватывается
+//   и возвращается как ошибка задания
+//
+// Пример:
+//   pool := vv.NewPool("db", 8, 100)
+//   job, err := pool.Submit(r.Context(), func(ctx context.Context, pid uint64) (interface{}, error) {
+//       return vv.QselectCtx(ctx, qp)
+//   })
+//   if err == vv.ErrQueueFull { http.Error(w, "busy", 503); return }
+//   res, err := job.Wait()
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ошибки пула воркеров
+var (
+	ErrQueueFull  = errors.New("Pool: job queue is full")
+	ErrPoolClosed = errors.New("Pool: pool is closed")
+)
+
+// Vjfn функция задания пула
+type Vjfn func(ctx context.Context, pid uint64) (interface{}, error)
+
+// Vjob задание пула
+type Vjob struct {
+	Pid uint64 // id обработки задания
+
+	ctx   context.Context
+	fn    Vjfn
+	ready chan struct{} // закрывается, когда Submit заполнил Pid и ctx принятого задания
+	done  chan struct{} // закрывается по окончании задания
+	res   interface{}   // результат
+	err   error         // ошибка
+	start time.Time     // время старта
+	dur   time.Duration // длительность
+}
+
+// Vpool пул воркеров
+type Vpool struct {
+	Name string // имя пула для лога
+	Log  *Vlg   // лог пула (nil - Vlogger)
+
+	mu      sync.RWMutex
+	closed  bool
+	q       chan *Vjob     // очередь заданий
+	wg      sync.WaitGroup // работающие воркеры
+	workers int            // число воркеров
+
+	running atomic.Int64  // выполняется заданий
+	done    atomic.Uint64 // выполнено заданий
+	failed  atomic.Uint64 // из них с ошибкой
+}
+
+// Vpst состояние пула воркеров
+type Vpst struct {
+	Workers int    // число воркеров
+	Queue   int    // заданий в очереди
+	Cap     int    // емкость очереди
+	Running int    // выполняется заданий
+	Done    uint64 // выполнено заданий
+	Failed  uint64 // из них с ошибкой
+}
+
+// NewPool -----------------------------------------------------------------------------
+// пул из workers воркеров с очередью на queue заданий
+func NewPool(name string, workers int, queue int) *Vpool {
+	if workers <= 0 {
+		workers = 1
+	}
+	if queue < 0 {
+		queue = 0
+	}
+
+	p := &Vpool{Name: name, q: make(chan *Vjob, queue), workers: workers}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+// Submit -----------------------------------------------------------------------------
+// постановка задания в очередь
+// - ctx - контекст задания: его отмена прерывает задание (если fn следит за ctx)
+// - при заполненной очереди возвращает ErrQueueFull, после Close - ErrPoolClosed
+// - id обработки берется только для принятого задания: отказы id не расходуют
+func (p *Vpool) Submit(ctx context.Context, fn Vjfn) (*Vjob, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	job := &Vjob{fn: fn, ready: make(chan struct{}), done: make(chan struct{})}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+	select {
+	case p.q <- job:
+	default:
+		pid, _ := PidFromCtx(ctx) // отказ логируется под id вызывающей обработки
+		p.log().Vlog(pid, "Pool "+p.Name+": job queue is full", Ewrn)
+		return nil, ErrQueueFull
+	}
+
+	job.Pid = Getpid() // id обработки задания; воркер ждет его в run
+	job.ctx = CtxWithPid(ctx, job.Pid)
+	close(job.ready)
+	return job, nil
+}
+
+// worker -----------------------------------------------------------------------------
+// воркер пула: выполняет задания из очереди до закрытия пула
+func (p *Vpool) worker() {
+	defer p.wg.Done()
+	for job := range p.q {
+		p.run(job)
+	}
+}
+
+// run -----------------------------------------------------------------------------
+// выполнение одного задания с логированием и перехватом паники
+func (p *Vpool) run(job *Vjob) {
+	<-job.ready
+	vl := p.log()
+	p.running.Add(1)
+	defer p.running.Add(-1)
+	defer close(job.done)
+
+	job.start = time.Now()
+	vl.Vlog(job.Pid, "Pool "+p.Name+": job start", Einf)
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				job.err = fmt.Errorf("panic: %v", r)
+				vl.Vlog(job.Pid, "Pool "+p.Name+": job panic: "+fmt.Sprint(r)+"\n"+string(debug.Stack()), Eftl)
+			}
+		}()
+		if err := job.ctx.Err(); err != nil { // задание отменили, пока оно стояло в очереди
+			job.err = err
+			return
+		}
+		job.res, job.err = job.fn(job.ctx, job.Pid)
+	}()
+
+	job.dur = time.Since(job.start)
+	p.done.Add(1)
+	if job.err != nil {
+		p.failed.Add(1)
+		vl.Vlog(job.Pid, "Pool "+p.Name+": job error after "+job.dur.String()+": "+job.err.Error(), Eerr)
+		return
+	}
+	vl.Vlog(job.Pid, "Pool "+p.Name+": job done in "+job.dur.String(), Einf)
+}
+
+// Close -----------------------------------------------------------------------------
+// закрытие пула: новые задания не принимаются, задания из очереди дорабатываются
+// - если ctx завершится раньше воркеров, возвращается ошибка ctx
+func (p *Vpool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.q)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats -----------------------------------------------------------------------------
+// состояние пула воркеров
+func (p *Vpool) Stats() Vpst {
+	return Vpst{
+		Workers: p.workers,
+		Queue:   len(p.q),
+		Cap:     cap(p.q),
+		Running: int(p.running.Load()),
+		Done:    p.done.Load(),
+		Failed:  p.failed.Load(),
+	}
+}
+
+// log -----------------------------------------------------------------------------
+func (p *Vpool) log() *Vlg {
+	if p.Log != nil {
+		return p.Log
+	}
+	return &Vlogger
+}
+
+// Done -----------------------------------------------------------------------------
+// канал, закрывающийся по окончании задания
+func (job *Vjob) Done() <-chan struct{} { return job.done }
+
+// Wait -----------------------------------------------------------------------------
+// ожидание окончания задания: результат и ошибка задания
+func (job *Vjob) Wait() (interface{}, error) {
+	<-job.done
+	return job.res, job.err
+}
+
+// Duration -----------------------------------------------------------------------------
+// длительность выполнения задания (0, пока задание не закончено)
+func (job *Vjob) Duration() time.Duration {
+	select {
+	case <-job.done:
+		return job.dur
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,156 @@
+package vv
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testPool пул с 1 воркером и очередью на 1 задание, воркер которого занят до release
+func testPool(t *testing.T) (p *Vpool, busy *Vjob, release chan struct{}) {
+	t.Helper()
+	p = NewPool("test", 1, 1)
+	p.Log = &Vlg{}
+	release = make(chan struct{})
+	started := make(chan struct{})
+	busy, err := p.Submit(context.Background(), func(ctx context.Context, pid uint64) (interface{}, error) {
+		close(started)
+		<-release
+		return "busy", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	return p, busy, release
+}
+
+func TestPoolSubmit(t *testing.T) {
+	p, busy, release := testPool(t)
+	before := vpidtotal.Load()
+
+	queued, err := p.Submit(context.Background(), func(ctx context.Context, pid uint64) (interface{}, error) {
+		if got := GetpidCtx(ctx); got != pid {
+			return nil, errors.New("pid in ctx differs from the job pid")
+		}
+		return pid, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := p.Submit(context.Background(), nil); err != ErrQueueFull {
+			t.Errorf("Submit to a full queue = %v, want ErrQueueFull", err)
+		}
+	}
+	if got := vpidtotal.Load() - before; got != 1 {
+		t.Errorf("pids taken = %d, want 1: rejected submits must not take pids", got)
+	}
+	if st := p.Stats(); st.Queue != 1 || st.Running != 1 {
+		t.Errorf("Stats = %+v, want 1 queued and 1 running", st)
+	}
+
+	close(release)
+	if res, err := queued.Wait(); err != nil || res != queued.Pid {
+		t.Errorf("queued job = %v, %v, want its pid %d", res, err, queued.Pid)
+	}
+	if res, _ := busy.Wait(); res != "busy" || busy.Duration() <= 0 {
+		t.Errorf("busy job = %v in %v", res, busy.Duration())
+	}
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	before = vpidtotal.Load()
+	if _, err := p.Submit(context.Background(), nil); err != ErrPoolClosed {
+		t.Errorf("Submit after Close = %v, want ErrPoolClosed", err)
+	}
+	if vpidtotal.Load() != before {
+		t.Error("Submit after Close took a pid")
+	}
+}
+
+func TestPoolPanic(t *testing.T) {
+	p := NewPool("test", 2, 10)
+	p.Log = &Vlg{}
+
+	// nil ctx - то же, что context.Background()
+	job, err := p.Submit(nil, func(ctx context.Context, pid uint64) (interface{}, error) {
+		panic("boom")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := job.Wait(); err == nil || err.Error() != "panic: boom" {
+		t.Errorf("panicked job error = %v, want panic: boom", err)
+	}
+
+	// пул продолжает работать после паники задания
+	job, _ = p.Submit(context.Background(), func(ctx context.Context, pid uint64) (interface{}, error) { return 1, nil })
+	if res, err := job.Wait(); res != 1 || err != nil {
+		t.Errorf("job after a panic = %v, %v", res, err)
+	}
+	p.Close(context.Background())
+	if st := p.Stats(); st.Done != 2 || st.Failed != 1 {
+		t.Errorf("Stats = %+v, want 2 done, 1 failed", st)
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	p, _, release := testPool(t)
+	var jobs []*Vjob
+	p2 := NewPool("drain", 1, 5)
+	p2.Log = &Vlg{}
+	block := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		job, err := p2.Submit(context.Background(), func(ctx context.Context, pid uint64) (interface{}, error) {
+			<-block
+			return "ok", nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	// Close с истекшим ctx не ждет, но и не бросает задания
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p2.Close(ctx); err == nil {
+		t.Error("Close with busy workers and an expired ctx: want error")
+	}
+	close(block)
+	if err := p2.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for i, job := range jobs {
+		select {
+		case <-job.Done():
+		default:
+			t.Fatalf("job %d is not done after Close returned", i)
+		}
+		if res, err := job.Wait(); res != "ok" || err != nil {
+			t.Errorf("job %d = %v, %v", i, res, err)
+		}
+	}
+
+	// задание, отмененное в очереди, не выполняется
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	ran := false
+	job, err := p.Submit(ctx2, func(ctx context.Context, pid uint64) (interface{}, error) {
+		ran = true
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel2()
+	close(release)
+	if _, err := job.Wait(); !errors.Is(err, context.Canceled) || ran {
+		t.Errorf("job cancelled in the queue: %v, ran = %v", err, ran)
+	}
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,474 @@
+package vv
+
+// Планировщик периодических воркеров:
+// - расписание задается cron-выражением из 5 полей "минута час день месяц день_недели"
+//   (*, списки через запятую, диапазоны a-b, шаги */n и a-b/n, имена jan..dec и sun..sat),
+//   сокращениями @hourly, @daily (@midnight), @weekly, @monthly, @yearly (@annually)
+//   или интервалом "@every 5m" / методом Every с необязательным случайным разбросом (jitter)
+// - каждый запуск идет под новым id обработки (в ctx, см. GetpidCtx)
+// - если предыдущий запуск задания еще не закончился, очередной пропускается и это пишется в лог
+// - запуск, который длился дольше периода до следующего срабатывания, пишется в лог как перерасход (overrun)
+// - Jobs показывает задания с временем следующего запуска, Upcoming - ближайшие запуски всех заданий
+// - цикл планировщика Run - обычный воркер: его запускает среда выполнения под своим супервизором
+// Планировщик среды выполнения по умолчанию: Vrun.Scheduler(); в нем же работает менеджер памяти.
+//
+// Пример:
+//   vv.Vrun.Scheduler().Add("backup", "30 2 * * *", func(ctx context.Context, pid uint64) error { ... })
+//   vv.Vrun.Scheduler().Every("cleanup", 10*time.Minute, time.Minute, cleanup)
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Vspec расписание: время следующего запуска после t (нулевое время - запусков больше нет)
+type Vspec interface {
+	Next(t time.Time) time.Time
+}
+
+// vcron расписание по cron-выражению; биты масок - допустимые значения полей
+type vcron struct {
+	min, hour, dom, mon, dow uint64
+	domAll, dowAll           bool // поле дня месяца / дня недели начинается с * (*, */2): не ограничивает день
+}
+
+// vevery расписание с фиксированным интервалом
+type vevery struct {
+	d      time.Duration // интервал
+	jitter time.Duration // max случайная добавка к интервалу
+}
+
+// Vsjob состояние задания планировщика
+type Vsjob struct {
+	Name     string        // имя задания
+	Spec     string        // расписание
+	Next     time.Time     // следующий запуск
+	Prev     time.Time     // последний запуск
+	Running  bool          // выполняется сейчас
+	Runs     int           // число запусков
+	Skipped  int           // пропущено запусков из-за незаконченного предыдущего
+	Overruns int           // запусков, длившихся дольше периода
+	LastDur  time.Duration // длительность последнего запуска
+	Err      string        // ошибка последнего запуска
+}
+
+// Vsrun предстоящий запуск задания
+type Vsrun struct {
+	Name string    // имя задания
+	Time time.Time // время запуска
+}
+
+// Vsch планировщик периодических воркеров; нулевое значение готово к работе
+type Vsch struct {
+	Log *Vlg // лог планировщика (nil - Vlogger)
+
+	mu      sync.Mutex
+	jobs    map[string]*vsjob // задания по именам
+	running map[string]bool   // имена заданий с идущим запуском (переживает замену задания)
+	wake    chan struct{}     // сигнал циклу Run о смене заданий
+}
+
+// vsjob задание планировщика
+type vsjob struct {
+	st   Vsjob // состояние (под Vsch.mu)
+	spec Vspec // расписание
+	fn   Vwfn  // функция задания
+}
+
+// cron-сокращения
+var cronAliases = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// имена месяцев и дней недели в cron-выражениях
+var (
+	cronMonths = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+	cronDays = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+)
+
+// ParseCron -----------------------------------------------------------------------------
+// разбор расписания: cron-выражение из 5 полей, cron-сокращение или "@every <интервал>"
+func ParseCron(spec string) (Vspec, error) {
+	spec = strings.TrimSpace(spec)
+
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(spec[len("@every "):]))
+		if err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			return nil, errors.New("Cron: interval must be positive: " + spec)
+		}
+		return vevery{d: d}, nil
+	}
+	if s, ok := cronAliases[spec]; ok {
+		spec = s
+	}
+
+	fs := strings.Fields(spec)
+	if len(fs) != 5 {
+		return nil, errors.New("Cron: expected 5 fields: " + spec)
+	}
+
+	var c vcron
+	var err error
+	if c.min, err = cronField(fs[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if c.hour, err = cronField(fs[1], 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if c.dom, err = cronField(fs[2], 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if c.mon, err = cronField(fs[3], 1, 12, cronMonths); err != nil {
+		return nil, err
+	}
+	if c.dow, err = cronField(fs[4], 0, 7, cronDays); err != nil {
+		return nil, err
+	}
+	if c.dow&(1<<7) != 0 { // 7 - тоже воскресенье
+		c.dow |= 1
+	}
+	c.domAll, c.dowAll = strings.HasPrefix(fs[2], "*"), strings.HasPrefix(fs[4], "*") // как в cron: "*/1" - тоже *
+	return c, nil
+}
+
+// cronField -----------------------------------------------------------------------------
+// разбор одного поля cron-выражения в битовую маску допустимых значений lo..hi
+func cronField(f string, lo int, hi int, names map[string]int) (uint64, error) {
+	val := func(s string) (int, error) {
+		if v, ok := names[strings.ToLower(s)]; ok {
+			return v, nil
+		}
+		return strconv.Atoi(s)
+	}
+
+	var bits uint64
+	for _, part := range strings.Split(f, ",") {
+		step, stepped := 1, false
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s <= 0 {
+				return 0, errors.New("Cron: bad step: " + part)
+			}
+			step, stepped, part = s, true, part[:i]
+		}
+
+		var a, b int
+		var err error
+		if part == "*" {
+			a, b = lo, hi
+		} else if i := strings.IndexByte(part, '-'); i > 0 {
+			if a, err = val(part[:i]); err == nil {
+				b, err = val(part[i+1:])
+			}
+		} else {
+			a, err = val(part)
+			b = a
+			if stepped { // "5/10" - с 5 до конца диапазона через 10
+				b = hi
+			}
+		}
+		if err != nil {
+			return 0, errors.New("Cron: bad value: " + part)
+		}
+		if a < lo || b > hi || a > b {
+			return 0, fmt.Errorf("Cron: value out of range %d-%d: %s", lo, hi, part)
+		}
+
+		for v := a; v <= b; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Next -----------------------------------------------------------------------------
+// ближайшая минута после t, подходящая под cron-выражение (ищем не дальше 5 лет)
+func (c vcron) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		y, m, d := t.Date()
+		switch {
+		case c.mon&(1<<uint(m)) == 0:
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatch(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(y, m, d, t.Hour(), 0, 0, 0, loc).Add(time.Hour)
+		case c.min&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatch -----------------------------------------------------------------------------
+// подходит ли день: как в cron, если заданы оба поля дня, достаточно совпадения любого из них
+func (c vcron) dayMatch(t time.Time) bool {
+	dm := c.dom&(1<<uint(t.Day())) != 0
+	wm := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAll || c.dowAll {
+		return dm && wm
+	}
+	return dm || wm
+}
+
+// Next -----------------------------------------------------------------------------
+func (e vevery) Next(t time.Time) time.Time {
+	d := e.d
+	if e.jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(e.jitter) + 1))
+	}
+	return t.Add(d)
+}
+
+// Add -----------------------------------------------------------------------------
+// добавление (замена) задания name по расписанию spec (см. ParseCron)
+func (sc *Vsch) Add(name string, spec string, fn Vwfn) error {
+	sp, err := ParseCron(spec)
+	if err != nil {
+		return err
+	}
+	sc.add(name, spec, sp, fn)
+	return nil
+}
+
+// Every -----------------------------------------------------------------------------
+// добавление (замена) задания name с интервалом d и случайной добавкой до jitter
+func (sc *Vsch) Every(name string, d time.Duration, jitter time.Duration, fn Vwfn) error {
+	if d <= 0 {
+		return errors.New("Scheduler: interval must be positive: " + name)
+	}
+	spec := "@every " + d.String()
+	if jitter > 0 {
+		spec += " ±" + jitter.String()
+	}
+	sc.add(name, spec, vevery{d: d, jitter: jitter}, fn)
+	return nil
+}
+
+// add -----------------------------------------------------------------------------
+func (sc *Vsch) add(name string, spec string, sp Vspec, fn Vwfn) {
+	sc.mu.Lock()
+	if sc.jobs == nil {
+		sc.jobs = make(map[string]*vsjob)
+	}
+	j := &vsjob{st: Vsjob{Name: name, Spec: spec, Next: sp.Next(time.Now())}, spec: sp, fn: fn}
+	if old, ok := sc.jobs[name]; ok { // замена: счетчики сохраняем; идущий запуск учитывается по имени в running
+		j.st.Prev, j.st.Runs = old.st.Prev, old.st.Runs
+		j.st.Skipped, j.st.Overruns, j.st.LastDur, j.st.Err = old.st.Skipped, old.st.Overruns, old.st.LastDur, old.st.Err
+	}
+	sc.jobs[name] = j
+	sc.mu.Unlock()
+	sc.poke()
+}
+
+// Del -----------------------------------------------------------------------------
+// удаление задания (уже идущий запуск доработает)
+func (sc *Vsch) Del(name string) {
+	sc.mu.Lock()
+	delete(sc.jobs, name)
+	sc.mu.Unlock()
+	sc.poke()
+}
+
+// poke -----------------------------------------------------------------------------
+// сигнал циклу Run пересчитать ближайший запуск
+func (sc *Vsch) poke() {
+	sc.mu.Lock()
+	if sc.wake == nil {
+		sc.wake = make(chan struct{}, 1)
+	}
+	wake := sc.wake
+	sc.mu.Unlock()
+
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run -----------------------------------------------------------------------------
+// цикл планировщика: запускает задания по расписанию до отмены ctx
+// - при отмене ctx идущим запускам отменяется их ctx, Run дожидается их окончания
+func (sc *Vsch) Run(ctx context.Context) error {
+	sc.poke() // создаем канал пробуждения
+	sc.mu.Lock()
+	wake := sc.wake
+	sc.mu.Unlock()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		now := time.Now()
+		next := now.Add(time.Hour) // пересчет не реже раза в час
+
+		sc.mu.Lock()
+		for _, j := range sc.jobs {
+			if j.st.Next.IsZero() {
+				continue
+			}
+			if !j.st.Next.After(now) {
+				sc.fire(ctx, &wg, j, now)
+			}
+			if !j.st.Next.IsZero() && j.st.Next.Before(next) {
+				next = j.st.Next
+			}
+		}
+		sc.mu.Unlock()
+
+		timer.Reset(time.Until(next))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-wake:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case <-timer.C:
+		}
+	}
+}
+
+// fire -----------------------------------------------------------------------------
+// срабатывание задания j (под sc.mu): запуск или пропуск, если предыдущий еще идет
+func (sc *Vsch) fire(ctx context.Context, wg *sync.WaitGroup, j *vsjob, now time.Time) {
+	vl := sc.log()
+	name := j.st.Name
+	j.st.Next = j.spec.Next(now)
+
+	if sc.running[name] {
+		j.st.Skipped++
+		vl.Vlog(0, "Scheduler "+name+": run skipped, previous run is still going", Ewrn)
+		return
+	}
+
+	if sc.running == nil {
+		sc.running = make(map[string]bool)
+	}
+	sc.running[name] = true
+	j.st.Prev = now
+	j.st.Runs++
+	period := j.st.Next.Sub(now) // на выполнение отведено время до следующего срабатывания
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		pid := Getpid() // каждый запуск - новый id обработки
+		vl.Vlog(pid, "Scheduler "+name+": run start", Edbg)
+		start := time.Now()
+		err := sc.call(CtxWithPid(ctx, pid), vl, name, j.fn, pid)
+		dur := time.Since(start)
+
+		sc.mu.Lock()
+		delete(sc.running, name)
+		if cur := sc.jobs[name]; cur != nil { // задание могли заменить, пока шел запуск
+			cur.st.LastDur, cur.st.Err = dur, ""
+			if err != nil {
+				cur.st.Err = err.Error()
+			}
+			if dur > period && period > 0 {
+				cur.st.Overruns++
+			}
+		}
+		sc.mu.Unlock()
+
+		if err != nil {
+			vl.Vlog(pid, "Scheduler "+name+": run error: "+err.Error(), Eerr)
+		}
+		if dur > period && period > 0 {
+			vl.Vlog(pid, "Scheduler "+name+": overrun: run took "+dur.String()+", period "+period.String(), Ewrn)
+		}
+		vl.Vlog(pid, "Scheduler "+name+": run done in "+dur.String(), Edbg)
+	}()
+}
+
+// call -----------------------------------------------------------------------------
+// один запуск задания с перехватом паники
+func (sc *Vsch) call(ctx context.Context, vl *Vlg, name string, fn Vwfn, pid uint64) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+			vl.Vlog(pid, "Scheduler "+name+": panic: "+fmt.Sprint(r)+"\n"+string(debug.Stack()), Eftl)
+		}
+	}()
+	return fn(ctx, pid)
+}
+
+// log -----------------------------------------------------------------------------
+func (sc *Vsch) log() *Vlg {
+	if sc.Log != nil {
+		return sc.Log
+	}
+	return &Vlogger
+}
+
+// Jobs -----------------------------------------------------------------------------
+// задания планировщика по именам
+func (sc *Vsch) Jobs() []Vsjob {
+	sc.mu.Lock()
+	jobs := make([]Vsjob, 0, len(sc.jobs))
+	for name, j := range sc.jobs {
+		st := j.st
+		st.Running = sc.running[name]
+		jobs = append(jobs, st)
+	}
+	sc.mu.Unlock()
+
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].Name < jobs[k].Name })
+	return jobs
+}
+
+// Upcoming -----------------------------------------------------------------------------
+// ближайшие n запусков всех заданий по времени (для интервалов с разбросом - ориентировочно)
+func (sc *Vsch) Upcoming(n int) []Vsrun {
+	var runs []Vsrun
+
+	sc.mu.Lock()
+	for _, j := range sc.jobs {
+		t := j.st.Next
+		for i := 0; i < n && !t.IsZero(); i++ {
+			runs = append(runs, Vsrun{Name: j.st.Name, Time: t})
+			t = j.spec.Next(t)
+		}
+	}
+	sc.mu.Unlock()
+
+	sort.Slice(runs, func(i, k int) bool {
+		if runs[i].Time.Equal(runs[k].Time) {
+			return runs[i].Name < runs[k].Name
+		}
+		return runs[i].Time.Before(runs[k].Time)
+	})
+	if len(runs) > n {
+		runs = runs[:n]
+	}
+	return runs
+}
@@ -0,0 +1,154 @@
+package vv
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseCronNext(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, err := time.ParseInLocation("2006-01-02 15:04", s, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+
+	for _, c := range []struct {
+		spec string
+		from string
+		want string
+	}{
+		{"* * * * *", "2024-01-02 10:00", "2024-01-02 10:01"},
+		{"*/15 * * * *", "2024-01-02 10:07", "2024-01-02 10:15"},
+		{"30 2 * * *", "2024-01-02 10:00", "2024-01-03 02:30"},
+		{"0 9-17/4 * * *", "2024-01-02 13:00", "2024-01-02 17:00"},
+		{"0 0 1 1 *", "2024-06-01 00:00", "2025-01-01 00:00"},
+		{"@hourly", "2024-01-02 10:59", "2024-01-02 11:00"},
+		{"@weekly", "2024-01-02 10:00", "2024-01-07 00:00"},
+		{"0 12 * jan,jul sat", "2024-02-01 00:00", "2024-07-06 12:00"},
+		{"0 0 29 2 *", "2024-03-01 00:00", "2028-02-29 00:00"},     // високосный день
+		{"0 0 1 * mon", "2024-01-02 10:00", "2024-01-08 00:00"},    // оба поля дня: любое из них
+		{"0 0 */1 * mon", "2024-01-02 10:00", "2024-01-08 00:00"},  // */1 не ограничивает день
+		{"0 0 * * */2", "2024-01-02 10:00", "2024-01-04 00:00"},    // вт -> чт
+		{"0 0 31 2 *", "2024-01-02 10:00", ""},                     // не бывает
+		{"0 0 */10 * 1-5", "2024-01-02 10:00", "2024-01-11 00:00"}, // день месяца с * - И
+		{"0 0 1-7 * 1", "2024-01-09 00:00", "2024-01-15 00:00"},    // оба поля без * - ИЛИ
+	} {
+		sp, err := ParseCron(c.spec)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", c.spec, err)
+			continue
+		}
+		got := sp.Next(at(c.from))
+		if c.want == "" {
+			if !got.IsZero() {
+				t.Errorf("%q after %s = %v, want no runs", c.spec, c.from, got)
+			}
+			continue
+		}
+		if !got.Equal(at(c.want)) {
+			t.Errorf("%q after %s = %v, want %s", c.spec, c.from, got.Format("2006-01-02 15:04"), c.want)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *",
+		"* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "* * * foo *", "@every", "@every -1s", "@often"} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("ParseCron(%q): want error", spec)
+		}
+	}
+}
+
+func TestEveryJitter(t *testing.T) {
+	e := vevery{d: time.Minute, jitter: 10 * time.Second}
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		if d := e.Next(now).Sub(now); d < time.Minute || d > 70*time.Second {
+			t.Fatalf("Next with jitter: %v, want 1m..1m10s", d)
+		}
+	}
+}
+
+func TestSchedulerReplaceRunning(t *testing.T) {
+	sc := &Vsch{Log: &Vlg{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sc.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	sc.Every("job", 10*time.Millisecond, 0, func(ctx context.Context, pid uint64) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		return nil
+	})
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("first job did not start")
+	}
+
+	var runs atomic.Int32
+	sc.Every("job", 10*time.Millisecond, 0, func(ctx context.Context, pid uint64) error {
+		runs.Add(1)
+		return nil
+	})
+	if jobs := sc.Jobs(); len(jobs) != 1 || !jobs[0].Running {
+		t.Errorf("replaced job while the old run goes: %+v, want Running", jobs)
+	}
+
+	time.Sleep(50 * time.Millisecond) // идущий запуск прежнего задания: новые пропускаются
+	if runs.Load() != 0 {
+		t.Error("replacement ran while the previous run was still going")
+	}
+
+	close(release)
+	for end := time.Now().Add(5 * time.Second); runs.Load() == 0; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(end) {
+			t.Fatal("replacement job never ran after the previous run finished")
+		}
+	}
+	if jobs := sc.Jobs(); jobs[0].Skipped == 0 {
+		t.Error("Skipped = 0, want skipped runs while the previous run was going")
+	}
+}
+
+func TestSchedulerUpcoming(t *testing.T) {
+	sc := &Vsch{Log: &Vlg{}}
+	sc.Add("a", "@every 1h", func(ctx context.Context, pid uint64) error { return nil })
+	sc.Add("b", "@every 100m", func(ctx context.Context, pid uint64) error { return nil })
+	if err := sc.Add("bad", "* *", nil); err == nil {
+		t.Error("Add with a bad spec: want error")
+	}
+
+	runs := sc.Upcoming(4)
+	want := []string{"a", "b", "a", "a"} // 1h, 1h40m, 2h, 3h
+	if len(runs) != len(want) {
+		t.Fatalf("Upcoming: %d runs, want %d", len(runs), len(want))
+	}
+	for i, r := range runs {
+		if r.Name != want[i] {
+			t.Errorf("Upcoming[%d] = %s, want %s", i, r.Name, want[i])
+		}
+	}
+
+	sc.Del("a")
+	if jobs := sc.Jobs(); len(jobs) != 1 || jobs[0].Name != "b" {
+		t.Errorf("Jobs after Del = %+v, want only b", jobs)
+	}
+}
@@ -0,0 +1,198 @@
+package vv
+
+// Менеджер памяти:
+// - вместо безусловного debug.FreeOSMemory() каждые 3 секунды память возвращается системе
+//   только когда она превышает заданные пороги
+// - состояние памяти читается из runtime/metrics: куча (живые объекты), свободная, но не возвращенная
+//   системе память кучи, и RSS (оценка: вся память рантайма минус возвращенная системе)
+// - пороги: HeapLimit - куча, RssLimit - RSS, IdleLimit - свободная память кучи;
+//   если ни один порог не задан, работает только IdleLimit = 64 МБ
+// - после освобождения следующее возможно не раньше чем через MinGap, чтобы не гонять полную
+//   сборку мусора, пока куча остается выше порога
+// - SoftLimit задает мягкий лимит памяти рантайма (debug.SetMemoryLimit)
+// - превышение порогов (давление на память) и его окончание пишутся в лог, каждое освобождение - тоже
+// В среде выполнения менеджер памяти - задание планировщика "vv.memory" (Vcfg.FreeMemory / Vcfg.Memory).
+//
+// Пример:
+//   vv.Vrun.Start(vv.Vcfg{Memory: &vv.Vmcfg{RssLimit: 512 << 20, SoftLimit: 768 << 20}})
+//   st := vv.Vrun.Memory().Stats()
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+// Vmcfg параметры менеджера памяти
+type Vmcfg struct {
+	Interval  time.Duration // период проверки в среде выполнения (0 - 10 сек)
+	HeapLimit uint64        // порог кучи, байт (0 - не проверять)
+	RssLimit  uint64        // порог RSS, байт (0 - не проверять)
+	IdleLimit uint64        // порог свободной, но не возвращенной системе памяти кучи, байт (0 - не проверять)
+	MinGap    time.Duration // min пауза между освобождениями памяти (0 - 1 мин)
+	SoftLimit int64         // мягкий лимит памяти рантайма, байт (0 - не менять)
+}
+
+// Vmst состояние памяти
+type Vmst struct {
+	Time     time.Time // время замера
+	Heap     uint64    // куча: память объектов, байт
+	HeapGoal uint64    // цель кучи для следующей сборки мусора, байт
+	Idle     uint64    // свободная, но не возвращенная системе память кучи, байт
+	Released uint64    // память кучи, возвращенная системе, байт
+	Total    uint64    // вся память, полученная рантаймом от системы, байт
+	Rss      uint64    // оценка RSS: Total - Released, байт
+	Limit    int64     // мягкий лимит памяти рантайма, байт
+	GCs      uint64    // число сборок мусора
+	Frees    int       // число принудительных освобождений памяти менеджером
+	LastFree time.Time // время последнего освобождения
+	Pressure bool      // память выше порогов HeapLimit / RssLimit
+}
+
+// Vmem менеджер памяти
+type Vmem struct {
+	Cfg Vmcfg // параметры
+	Log *Vlg  // лог менеджера (nil - Vlogger)
+
+	mu       sync.Mutex
+	frees    int       // число освобождений
+	last     time.Time // время последнего освобождения
+	pressure bool      // память выше порогов
+}
+
+// метрики runtime/metrics для Vmst
+var vmemNames = []string{
+	"/memory/classes/heap/objects:bytes",
+	"/gc/heap/goal:bytes",
+	"/memory/classes/heap/free:bytes",
+	"/memory/classes/heap/released:bytes",
+	"/memory/classes/total:bytes",
+	"/gc/cycles/total:gc-cycles",
+}
+
+// NewVmem -----------------------------------------------------------------------------
+// менеджер памяти с параметрами cfg; если задан cfg.SoftLimit, он сразу устанавливается
+func NewVmem(cfg Vmcfg) *Vmem {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	if cfg.MinGap <= 0 {
+		cfg.MinGap = time.Minute
+	}
+	if cfg.HeapLimit == 0 && cfg.RssLimit == 0 && cfg.IdleLimit == 0 {
+		cfg.IdleLimit = 64 << 20
+	}
+	if cfg.SoftLimit > 0 {
+		debug.SetMemoryLimit(cfg.SoftLimit)
+	}
+	return &Vmem{Cfg: cfg}
+}
+
+// Stats -----------------------------------------------------------------------------
+// текущее состояние памяти
+func (m *Vmem) Stats() Vmst {
+	ss := make([]metrics.Sample, len(vmemNames))
+	for i, name := range vmemNames {
+		ss[i].Name = name
+	}
+	metrics.Read(ss)
+
+	val := func(i int) uint64 {
+		if ss[i].Value.Kind() != metrics.KindUint64 {
+			return 0
+		}
+		return ss[i].Value.Uint64()
+	}
+
+	st := Vmst{
+		Time:     time.Now(),
+		Heap:     val(0),
+		HeapGoal: val(1),
+		Idle:     val(2),
+		Released: val(3),
+		Total:    val(4),
+		GCs:      val(5),
+		Limit:    debug.SetMemoryLimit(-1), // отрицательное значение только читает лимит
+	}
+	st.Rss = st.Total - st.Released
+
+	m.mu.Lock()
+	st.Frees, st.LastFree, st.Pressure = m.frees, m.last, m.pressure
+	m.mu.Unlock()
+	return st
+}
+
+// SetLimit -----------------------------------------------------------------------------
+// установка мягкого лимита памяти рантайма; возвращает прежний лимит
+func (m *Vmem) SetLimit(limit int64) int64 {
+	m.mu.Lock()
+	m.Cfg.SoftLimit = limit
+	m.mu.Unlock()
+	return debug.SetMemoryLimit(limit)
+}
+
+// Check -----------------------------------------------------------------------------
+// проверка памяти и, если она выше порогов, возврат памяти системе
+// - подходит как функция задания планировщика: sch.Every("mem", 10*time.Second, 0, m.Check)
+func (m *Vmem) Check(ctx context.Context, pid uint64) error {
+	vl := m.log()
+	st := m.Stats()
+
+	m.mu.Lock()
+	cfg := m.Cfg
+	var why string // причина давления на память
+	switch {
+	case cfg.HeapLimit > 0 && st.Heap > cfg.HeapLimit:
+		why = "heap " + vmemMB(st.Heap) + " > " + vmemMB(cfg.HeapLimit)
+	case cfg.RssLimit > 0 && st.Rss > cfg.RssLimit:
+		why = "rss " + vmemMB(st.Rss) + " > " + vmemMB(cfg.RssLimit)
+	}
+	idle := cfg.IdleLimit > 0 && st.Idle > cfg.IdleLimit
+
+	was := m.pressure
+	m.pressure = why != ""
+	free := (why != "" || idle) && time.Since(m.last) >= cfg.MinGap
+	if free {
+		m.frees++
+		m.last = time.Now()
+	}
+	m.mu.Unlock()
+
+	// смена состояния пишется в лог сама по себе: пока MinGap не дает освобождать, вход в давление тоже виден
+	switch {
+	case !was && why != "":
+		vl.Vlog(pid, "Memory pressure: "+why, Ewrn)
+	case was && why == "":
+		vl.Vlog(pid, "Memory pressure is over: heap "+vmemMB(st.Heap)+", rss "+vmemMB(st.Rss), Einf)
+	}
+	if !free {
+		return nil
+	}
+
+	debug.FreeOSMemory() // вызов уборщика мусора с возвратом памяти системе
+	after := m.Stats()
+	freed := "released " + vmemMB(st.Rss-min(st.Rss, after.Rss)) + ", rss " + vmemMB(after.Rss)
+
+	if why == "" {
+		why = "idle " + vmemMB(st.Idle) + " > " + vmemMB(cfg.IdleLimit)
+	}
+	vl.Vlog(pid, "Memory freed ("+why+"): "+freed, Einf)
+	return nil
+}
+
+// log -----------------------------------------------------------------------------
+func (m *Vmem) log() *Vlg {
+	if m.Log != nil {
+		return m.Log
+	}
+	return &Vlogger
+}
+
+// vmemMB -----------------------------------------------------------------------------
+// объем памяти в МБ для лога
+func vmemMB(n uint64) string {
+	return fmt.Sprintf("%.1fMB", float64(n)/(1<<20))
+}
@@ -0,0 +1,133 @@
+package vv
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testVmem менеджер памяти с параметрами cfg, пишущий в кольцевой буфер запущенной среды, и его события
+func testVmem(t *testing.T, cfg Vmcfg) (*Vmem, func() []string) {
+	t.Helper()
+	rt := NewVrt()
+	ring := NewRingSink(100)
+	rt.Log.AddSink("ring", ring, Ldbg)
+	if err := rt.Start(Vcfg{Quiet: true}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rt.Shutdown(context.Background()) })
+
+	m := NewVmem(cfg)
+	m.Log = rt.Log
+	logs := func() []string { // события менеджера памяти после остановки среды
+		if err := rt.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		var s []string
+		for _, vle := range ring.Events() {
+			if strings.HasPrefix(vle.Estr(), "Memory") {
+				s = append(s, Etname(vle.Etype())+" "+vle.Estr())
+			}
+		}
+		return s
+	}
+	return m, logs
+}
+
+// testPrefixes проверка, что события начинаются с want по порядку
+func testPrefixes(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("events %q, want %d events %q", got, len(want), want)
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Errorf("event %d = %q, want %q...", i, got[i], want[i])
+		}
+	}
+}
+
+var testGarbage []byte // мусор для порога свободной памяти кучи
+
+func TestVmemDefaults(t *testing.T) {
+	m := NewVmem(Vmcfg{})
+	if m.Cfg.Interval != 10*time.Second || m.Cfg.MinGap != time.Minute || m.Cfg.IdleLimit != 64<<20 {
+		t.Errorf("defaults = %+v", m.Cfg)
+	}
+	if m := NewVmem(Vmcfg{HeapLimit: 1}); m.Cfg.IdleLimit != 0 {
+		t.Errorf("IdleLimit with HeapLimit set = %d, want 0", m.Cfg.IdleLimit)
+	}
+	st := m.Stats()
+	if st.Heap == 0 || st.Total == 0 || st.Rss == 0 || st.Rss > st.Total {
+		t.Errorf("Stats = %+v", st)
+	}
+}
+
+func TestVmemThresholds(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		cfg  Vmcfg
+		why  string
+	}{
+		{"heap", Vmcfg{HeapLimit: 1}, "Wrn Memory pressure: heap"},
+		{"rss", Vmcfg{RssLimit: 1}, "Wrn Memory pressure: rss"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			m, logs := testVmem(t, c.cfg)
+			m.Check(context.Background(), 1)
+			if st := m.Stats(); !st.Pressure || st.Frees != 1 || st.LastFree.IsZero() {
+				t.Errorf("Stats after Check = %+v, want pressure and one free", st)
+			}
+			testPrefixes(t, logs(), c.why, "Inf Memory freed ("+c.name)
+		})
+	}
+
+	// порог свободной памяти кучи: освобождение без давления
+	m, logs := testVmem(t, Vmcfg{IdleLimit: 1})
+	testGarbage = make([]byte, 8<<20)
+	testGarbage = nil
+	runtime.GC() // память мусора остается в куче свободной
+	m.Check(context.Background(), 1)
+	if st := m.Stats(); st.Pressure || st.Frees != 1 {
+		t.Errorf("idle: Stats = %+v, want one free without pressure", st)
+	}
+	testPrefixes(t, logs(), "Inf Memory freed (idle")
+
+	// пороги не превышены: ничего не происходит
+	m, logs = testVmem(t, Vmcfg{HeapLimit: 1 << 50, RssLimit: 1 << 50})
+	m.Check(context.Background(), 1)
+	if st := m.Stats(); st.Pressure || st.Frees != 0 {
+		t.Errorf("below limits: Stats = %+v", st)
+	}
+	testPrefixes(t, logs())
+}
+
+func TestVmemMinGap(t *testing.T) {
+	m, logs := testVmem(t, Vmcfg{HeapLimit: 1, MinGap: time.Hour})
+
+	// освобождение было только что: MinGap не дает освобождать, но вход в давление пишется в лог
+	m.last = time.Now()
+	m.Check(context.Background(), 1)
+	m.Check(context.Background(), 1)
+	if st := m.Stats(); !st.Pressure || st.Frees != 0 {
+		t.Errorf("within MinGap: Stats = %+v, want pressure without frees", st)
+	}
+
+	m.Cfg.HeapLimit = 1 << 50
+	m.Check(context.Background(), 1)
+	if m.Stats().Pressure {
+		t.Error("pressure after the heap dropped below the limit")
+	}
+
+	// после MinGap снова освобождает
+	m.Cfg.HeapLimit, m.Cfg.MinGap = 1, time.Millisecond
+	time.Sleep(2 * time.Millisecond)
+	m.Check(context.Background(), 1)
+	if st := m.Stats(); st.Frees != 1 {
+		t.Errorf("after MinGap: Frees = %d, want 1", st.Frees)
+	}
+	testPrefixes(t, logs(), "Wrn Memory pressure: heap", "Inf Memory pressure is over",
+		"Wrn Memory pressure: heap", "Inf Memory freed (heap")
+}
@@ -0,0 +1,451 @@
+package vv
+
+// Метрики:
+// - реестр метрик без внешних зависимостей: счетчики (Vcnt), измерители (Vgau), гистограммы (Vhst)
+//   и функции-измерители, значение которых считается в момент чтения (GaugeFunc, CounterFunc)
+// - метрика с метками регистрируется через *Vec, значения меток задаются в With в порядке имен меток
+// - повторная регистрация метрики с тем же именем и типом возвращает уже зарегистрированную
+// - MetricsHandler отдает метрики в текстовом формате Prometheus (version 0.0.4)
+// Vmetrics - реестр по умолчанию; в нем сразу есть метрики пакета:
+//   vv_sql_queries_total, vv_sql_query_duration_seconds - sql-запросы QselectCtx (Qselect, Qrow) и QexeCtx (Qexe)
+//   vv_static_files_total, vv_static_bytes_total, vv_static_duration_seconds - StatToHttp и StatToByte
+//   vv_log_queue_length, vv_log_queue_capacity, vv_log_dropped_total, vv_log_spilled_total - канал лога Vlogger
+//   vv_pids_total - выдано id обработки за время работы процесса (не сбрасывается при SetPidgen)
+//
+// Пример:
+//   var hits = vv.Vmetrics.CounterVec("app_hits_total", "Page hits", "page")
+//   hits.With("/index").Inc()
+//   http.Handle("/metrics", vv.MetricsHandler())
+
+import (
+	"bufio"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// типы метрик
+const (
+	MtCounter   = "counter"
+	MtGauge     = "gauge"
+	MtHistogram = "histogram"
+)
+
+// DefBuckets границы корзин гистограммы по умолчанию, секунд
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Vmreg реестр метрик; нулевое значение готово к работе
+type Vmreg struct {
+	mu   sync.RWMutex
+	fams map[string]*vmfam // семейства метрик по именам
+}
+
+// Vmetrics реестр метрик по умолчанию
+var Vmetrics Vmreg
+
+// vmfam семейство метрик: одно имя, разные значения меток
+type vmfam struct {
+	name    string
+	help    string
+	kind    string         // MtCounter, MtGauge, MtHistogram
+	labels  []string       // имена меток
+	buckets []float64      // границы корзин гистограммы
+	fn      func() float64 // функция значения (GaugeFunc, CounterFunc)
+
+	mu  sync.RWMutex
+	ser map[string]*vmser // ряды по значениям меток
+}
+
+// vmser ряд метрики: значения меток и значение
+type vmser struct {
+	vals   []string        // значения меток
+	v      atomic.Uint64   // значение (биты float64); для гистограммы - сумма наблюдений
+	counts []atomic.Uint64 // гистограмма: число наблюдений по корзинам (не накопительно)
+	n      atomic.Uint64   // гистограмма: число наблюдений
+}
+
+// Vcnt счетчик: только растет
+type Vcnt struct{ s *vmser }
+
+// Vgau измеритель: произвольное значение
+type Vgau struct{ s *vmser }
+
+// Vhst гистограмма
+type Vhst struct {
+	s       *vmser
+	buckets []float64
+}
+
+// Vcntv счетчик с метками
+type Vcntv struct{ f *vmfam }
+
+// Vgauv измеритель с метками
+type Vgauv struct{ f *vmfam }
+
+// Vhstv гистограмма с метками
+type Vhstv struct{ f *vmfam }
+
+// Counter -----------------------------------------------------------------------------
+// регистрация счетчика без меток
+func (r *Vmreg) Counter(name string, help string) *Vcnt {
+	return &Vcnt{r.reg(name, help, MtCounter, nil, nil, nil).series(nil)}
+}
+
+// CounterVec -----------------------------------------------------------------------------
+// регистрация счетчика с метками labels
+func (r *Vmreg) CounterVec(name string, help string, labels ...string) *Vcntv {
+	return &Vcntv{r.reg(name, help, MtCounter, labels, nil, nil)}
+}
+
+// CounterFunc -----------------------------------------------------------------------------
+// регистрация счетчика, значение которого возвращает fn в момент чтения
+func (r *Vmreg) CounterFunc(name string, help string, fn func() float64) {
+	r.reg(name, help, MtCounter, nil, nil, fn)
+}
+
+// Gauge -----------------------------------------------------------------------------
+// регистрация измерителя без меток
+func (r *Vmreg) Gauge(name string, help string) *Vgau {
+	return &Vgau{r.reg(name, help, MtGauge, nil, nil, nil).series(nil)}
+}
+
+// GaugeVec -----------------------------------------------------------------------------
+// регистрация измерителя с метками labels
+func (r *Vmreg) GaugeVec(name string, help string, labels ...string) *Vgauv {
+	return &Vgauv{r.reg(name, help, MtGauge, labels, nil, nil)}
+}
+
+// GaugeFunc -----------------------------------------------------------------------------
+// регистрация измерителя, значение которого возвращает fn в момент чтения
+func (r *Vmreg) GaugeFunc(name string, help string, fn func() float64) {
+	r.reg(name, help, MtGauge, nil, nil, fn)
+}
+
+// Histogram -----------------------------------------------------------------------------
+// регистрация гистограммы без меток с границами корзин buckets (nil - DefBuckets)
+func (r *Vmreg) Histogram(name string, help string, buckets []float64) *Vhst {
+	f := r.reg(name, help, MtHistogram, nil, buckets, nil)
+	return &Vhst{f.series(nil), f.buckets}
+}
+
+// HistogramVec -----------------------------------------------------------------------------
+// регистрация гистограммы с метками labels и границами корзин buckets (nil - DefBuckets)
+func (r *Vmreg) HistogramVec(name string, help string, buckets []float64, labels ...string) *Vhstv {
+	return &Vhstv{r.reg(name, help, MtHistogram, labels, buckets, nil)}
+}
+
+// Unregister -----------------------------------------------------------------------------
+// удаление метрики из реестра
+func (r *Vmreg) Unregister(name string) {
+	r.mu.Lock()
+	delete(r.fams, name)
+	r.mu.Unlock()
+}
+
+// reg -----------------------------------------------------------------------------
+// регистрация семейства метрик; если оно уже есть с тем же типом - возвращается оно
+// - другой тип или другие метки у того же имени - ошибка программы: паника
+func (r *Vmreg) reg(name string, help string, kind string, labels []string, buckets []float64, fn func() float64) *vmfam {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f, ok := r.fams[name]; ok {
+		if f.kind != kind || strings.Join(f.labels, ",") != strings.Join(labels, ",") {
+			panic("Metrics: metric " + name + " is already registered as " + f.kind + " with labels [" + strings.Join(f.labels, ",") + "]")
+		}
+		if fn != nil {
+			f.fn = fn
+		}
+		return f
+	}
+
+	if kind == MtHistogram {
+		if len(buckets) == 0 {
+			buckets = DefBuckets
+		}
+		buckets = append([]float64(nil), buckets...)
+		sort.Float64s(buckets)
+	}
+
+	f := &vmfam{name: name, help: help, kind: kind, labels: labels, buckets: buckets, fn: fn, ser: make(map[string]*vmser)}
+	if r.fams == nil {
+		r.fams = make(map[string]*vmfam)
+	}
+	r.fams[name] = f
+	return f
+}
+
+// series -----------------------------------------------------------------------------
+// ряд семейства с значениями меток vals (создается при первом обращении)
+func (f *vmfam) series(vals []string) *vmser {
+	if len(vals) != len(f.labels) {
+		panic("Metrics: metric " + f.name + " wants " + strconv.Itoa(len(f.labels)) + " label values, got " + strconv.Itoa(len(vals)))
+	}
+	key := strings.Join(vals, "\xff")
+
+	f.mu.RLock()
+	s, ok := f.ser[key]
+	f.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s, ok = f.ser[key]; !ok {
+		s = &vmser{vals: append([]string(nil), vals...)}
+		if f.kind == MtHistogram {
+			s.counts = make([]atomic.Uint64, len(f.buckets))
+		}
+		f.ser[key] = s
+	}
+	return s
+}
+
+// add -----------------------------------------------------------------------------
+// атомарное прибавление к значению ряда
+func (s *vmser) add(d float64) {
+	for {
+		old := s.v.Load()
+		if s.v.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+d)) {
+			return
+		}
+	}
+}
+
+// value -----------------------------------------------------------------------------
+func (s *vmser) value() float64 { return math.Float64frombits(s.v.Load()) }
+
+// With -----------------------------------------------------------------------------
+// счетчик с значениями меток vals
+func (v *Vcntv) With(vals ...string) *Vcnt { return &Vcnt{v.f.series(vals)} }
+
+// With -----------------------------------------------------------------------------
+// измеритель с значениями меток vals
+func (v *Vgauv) With(vals ...string) *Vgau { return &Vgau{v.f.series(vals)} }
+
+// With -----------------------------------------------------------------------------
+// гистограмма с значениями меток vals
+func (v *Vhstv) With(vals ...string) *Vhst { return &Vhst{v.f.series(vals), v.f.buckets} }
+
+// Inc -----------------------------------------------------------------------------
+func (c *Vcnt) Inc() { c.s.add(1) }
+
+// Add -----------------------------------------------------------------------------
+// прибавление d к счетчику (отрицательные d игнорируются: счетчик только растет)
+func (c *Vcnt) Add(d float64) {
+	if d > 0 {
+		c.s.add(d)
+	}
+}
+
+// Value -----------------------------------------------------------------------------
+func (c *Vcnt) Value() float64 { return c.s.value() }
+
+// Set -----------------------------------------------------------------------------
+func (g *Vgau) Set(v float64) { g.s.v.Store(math.Float64bits(v)) }
+
+// Add -----------------------------------------------------------------------------
+func (g *Vgau) Add(d float64) { g.s.add(d) }
+
+// Inc -----------------------------------------------------------------------------
+func (g *Vgau) Inc() { g.s.add(1) }
+
+// Dec -----------------------------------------------------------------------------
+func (g *Vgau) Dec() { g.s.add(-1) }
+
+// Value -----------------------------------------------------------------------------
+func (g *Vgau) Value() float64 { return g.s.value() }
+
+// Observe -----------------------------------------------------------------------------
+// наблюдение значения v
+func (h *Vhst) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v) // первая корзина с границей >= v
+	if i < len(h.buckets) {
+		h.s.counts[i].Add(1)
+	}
+	h.s.n.Add(1)
+	h.s.add(v)
+}
+
+// Since -----------------------------------------------------------------------------
+// наблюдение длительности от start до сейчас, в секундах
+func (h *Vhst) Since(start time.Time) { h.Observe(time.Since(start).Seconds()) }
+
+// Count -----------------------------------------------------------------------------
+// число наблюдений
+func (h *Vhst) Count() uint64 { return h.s.n.Load() }
+
+// Sum -----------------------------------------------------------------------------
+// сумма наблюдений
+func (h *Vhst) Sum() float64 { return h.s.value() }
+
+// Handler -----------------------------------------------------------------------------
+// web server: метрики реестра в текстовом формате Prometheus
+func (r *Vmreg) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		bw := bufio.NewWriter(w)
+		r.write(bw)
+		bw.Flush()
+	})
+}
+
+// MetricsHandler -----------------------------------------------------------------------------
+// web server: метрики реестра по умолчанию Vmetrics в текстовом формате Prometheus
+func MetricsHandler() http.Handler {
+	return Vmetrics.Handler()
+}
+
+// write -----------------------------------------------------------------------------
+// вывод всех метрик реестра в текстовом формате Prometheus, по именам
+func (r *Vmreg) write(w *bufio.Writer) {
+	r.mu.RLock()
+	fams := make([]*vmfam, 0, len(r.fams))
+	for _, f := range r.fams {
+		fams = append(fams, f)
+	}
+	r.mu.RUnlock()
+	sort.Slice(fams, func(i, j int) bool { return fams[i].name < fams[j].name })
+
+	for _, f := range fams {
+		if f.help != "" {
+			w.WriteString("# HELP " + f.name + " " + vmEscape(f.help, false) + "\n")
+		}
+		w.WriteString("# TYPE " + f.name + " " + f.kind + "\n")
+
+		if f.fn != nil {
+			w.WriteString(f.name + " " + vmFloat(f.fn()) + "\n")
+			continue
+		}
+
+		f.mu.RLock()
+		ser := make([]*vmser, 0, len(f.ser))
+		for _, s := range f.ser {
+			ser = append(ser, s)
+		}
+		f.mu.RUnlock()
+		sort.Slice(ser, func(i, j int) bool {
+			return strings.Join(ser[i].vals, "\xff") < strings.Join(ser[j].vals, "\xff")
+		})
+
+		for _, s := range ser {
+			if f.kind != MtHistogram {
+				w.WriteString(f.name + vmLabels(f.labels, s.vals, "") + " " + vmFloat(s.value()) + "\n")
+				continue
+			}
+			var cum uint64 // корзины в формате Prometheus накопительные
+			for i, b := range f.buckets {
+				cum += s.counts[i].Load()
+				w.WriteString(f.name + "_bucket" + vmLabels(f.labels, s.vals, vmFloat(b)) + " " + strconv.FormatUint(cum, 10) + "\n")
+			}
+			n := s.n.Load()
+			w.WriteString(f.name + "_bucket" + vmLabels(f.labels, s.vals, "+Inf") + " " + strconv.FormatUint(n, 10) + "\n")
+			w.WriteString(f.name + "_sum" + vmLabels(f.labels, s.vals, "") + " " + vmFloat(s.value()) + "\n")
+			w.WriteString(f.name + "_count" + vmLabels(f.labels, s.vals, "") + " " + strconv.FormatUint(n, 10) + "\n")
+		}
+	}
+}
+
+// vmLabels -----------------------------------------------------------------------------
+// метки ряда в формате Prometheus: {a="1",b="2"}; le - граница корзины гистограммы ("" - нет)
+func vmLabels(names []string, vals []string, le string) string {
+	if len(names) == 0 && le == "" {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name + `="` + vmEscape(vals[i], true) + `"`)
+	}
+	if le != "" {
+		if len(names) > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(`le="` + le + `"`)
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// vmEscape -----------------------------------------------------------------------------
+// экранирование текста справки (\ и перевод строки) и значения метки (еще и ")
+func vmEscape(s string, quote bool) string {
+	r := strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	if quote {
+		r = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	}
+	return r.Replace(s)
+}
+
+// vmFloat -----------------------------------------------------------------------------
+// число в формате Prometheus
+func vmFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// метрики пакета
+var (
+	vmSqlTotal  = Vmetrics.CounterVec("vv_sql_queries_total", "SQL queries run by QselectCtx and QexeCtx.", "op", "status")
+	vmSqlDur    = Vmetrics.HistogramVec("vv_sql_query_duration_seconds", "SQL query duration in seconds.", nil, "op")
+	vmStatTotal = Vmetrics.CounterVec("vv_static_files_total", "Static files served by StatToHttp and read by StatToByte.", "func", "status")
+	vmStatBytes = Vmetrics.CounterVec("vv_static_bytes_total", "Bytes of static files served or read.", "func")
+	vmStatDur   = Vmetrics.HistogramVec("vv_static_duration_seconds", "Static file serving duration in seconds.", nil, "func")
+)
+
+// значения метки status метрик пакета
+const (
+	vmStatusOK    = "ok"
+	vmStatusError = "error"
+)
+
+func init() {
+	Vmetrics.GaugeFunc("vv_log_queue_length", "Events waiting in the Vlogger channel.", func() float64 {
+		return float64(Vlogger.Stats().Queue)
+	})
+	Vmetrics.GaugeFunc("vv_log_queue_capacity", "Capacity of the Vlogger channel.", func() float64 {
+		return float64(Vlogger.Stats().Cap)
+	})
+	Vmetrics.CounterFunc("vv_log_dropped_total", "Vlogger events dropped on a full channel.", func() float64 {
+		return float64(Vlogger.Stats().Dropped)
+	})
+	Vmetrics.CounterFunc("vv_log_spilled_total", "Vlogger events spilled to disk on a full channel.", func() float64 {
+		return float64(Vlogger.Stats().Spilled)
+	})
+	Vmetrics.CounterFunc("vv_pids_total", "Process ids issued since the process start.", func() float64 {
+		return float64(vpidtotal.Load())
+	})
+}
+
+// vmSql -----------------------------------------------------------------------------
+// учет sql-запроса op, начатого в start, с результатом err
+func vmSql(op string, start time.Time, err error) {
+	vmSqlDur.With(op).Since(start)
+	vmSqlTotal.With(op, Vifs(err == nil, vmStatusOK, vmStatusError)).Inc()
+}
+
+// vmStatic -----------------------------------------------------------------------------
+// учет статического файла, отданного (прочитанного) функцией fn за время от start
+func vmStatic(fn string, status string, n int, start time.Time) {
+	vmStatDur.With(fn).Since(start)
+	vmStatTotal.With(fn, status).Inc()
+	if n > 0 {
+		vmStatBytes.With(fn).Add(float64(n))
+	}
+}
@@ -0,0 +1,162 @@
+package vv
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testScrape текст метрик реестра r, полученный через его обработчик
+func testScrape(t *testing.T, r *Vmreg) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	b, err := io.ReadAll(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// testLines проверка, что в тексте метрик есть все строки want
+func testLines(t *testing.T, text string, want ...string) {
+	t.Helper()
+	lines := make(map[string]bool)
+	for _, l := range strings.Split(text, "\n") {
+		lines[l] = true
+	}
+	for _, l := range want {
+		if !lines[l] {
+			t.Errorf("no line %q in\n%s", l, text)
+		}
+	}
+}
+
+func TestMetricsRegister(t *testing.T) {
+	var r Vmreg
+	c := r.Counter("t_hits_total", "Hits.")
+	c.Inc()
+	c.Add(2)
+	c.Add(-5) // счетчик только растет
+	if c.Value() != 3 {
+		t.Errorf("counter = %v, want 3", c.Value())
+	}
+	if r.Counter("t_hits_total", "Hits.").Value() != 3 {
+		t.Error("repeated Counter registration: want the registered counter")
+	}
+
+	g := r.Gauge("t_temp", "Temperature.")
+	g.Set(10)
+	g.Inc()
+	g.Add(0.5)
+	g.Dec()
+	if g.Value() != 10.5 {
+		t.Errorf("gauge = %v, want 10.5", g.Value())
+	}
+
+	h := r.Histogram("t_dur_seconds", "Duration.", []float64{1, 0.1})
+	h.Observe(0.05)
+	h.Observe(2)
+	if h.Count() != 2 || h.Sum() != 2.05 {
+		t.Errorf("histogram count %d sum %v, want 2 2.05", h.Count(), h.Sum())
+	}
+
+	r.Unregister("t_temp")
+	if text := testScrape(t, &r); strings.Contains(text, "t_temp") {
+		t.Errorf("unregistered metric in\n%s", text)
+	}
+}
+
+func TestMetricsDuplicate(t *testing.T) {
+	var r Vmreg
+	r.CounterVec("t_req_total", "Requests.", "code")
+
+	for _, c := range []struct {
+		name string
+		reg  func()
+	}{
+		{"other kind", func() { r.Gauge("t_req_total", "Requests.") }},
+		{"other labels", func() { r.CounterVec("t_req_total", "Requests.", "method") }},
+		{"no labels", func() { r.Counter("t_req_total", "Requests.") }},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			defer func() {
+				if p := recover(); p == nil || !strings.Contains(p.(string), "t_req_total is already registered as counter with labels [code]") {
+					t.Errorf("panic = %v", p)
+				}
+			}()
+			c.reg()
+		})
+	}
+
+	defer func() {
+		if p := recover(); p == nil || !strings.Contains(p.(string), "wants 1 label values, got 2") {
+			t.Errorf("With with wrong label count: panic = %v", p)
+		}
+	}()
+	r.CounterVec("t_req_total", "Requests.", "code").With("200", "GET")
+}
+
+func TestMetricsText(t *testing.T) {
+	var r Vmreg
+	r.CounterVec("t_req_total", "Requests by \"path\\code\",\nall.", "path", "code").With("/a\"b\\c\nd", "200").Add(3)
+	r.Gauge("t_up", "").Set(1)
+	r.GaugeFunc("t_fn", "Func gauge.", func() float64 { return 42 })
+	h := r.HistogramVec("t_dur_seconds", "Duration.", []float64{0.1, 1}, "op")
+	for _, v := range []float64{0.05, 0.1, 0.5, 3} {
+		h.With("get").Observe(v)
+	}
+
+	text := testScrape(t, &r)
+	testLines(t, text,
+		`# HELP t_req_total Requests by "path\\code",\nall.`, // в справке кавычки не экранируются
+		"# TYPE t_req_total counter",
+		`t_req_total{path="/a\"b\\c\nd",code="200"} 3`,
+		"# TYPE t_up gauge",
+		"t_up 1",
+		"# HELP t_fn Func gauge.",
+		"# TYPE t_fn gauge",
+		"t_fn 42",
+		"# HELP t_dur_seconds Duration.",
+		"# TYPE t_dur_seconds histogram",
+		`t_dur_seconds_bucket{op="get",le="0.1"} 2`, // граница входит в корзину
+		`t_dur_seconds_bucket{op="get",le="1"} 3`,
+		`t_dur_seconds_bucket{op="get",le="+Inf"} 4`,
+		`t_dur_seconds_sum{op="get"} 3.65`,
+		`t_dur_seconds_count{op="get"} 4`,
+	)
+	if strings.Contains(text, "# HELP t_up") {
+		t.Error("HELP line for a metric without help")
+	}
+
+	// метрики по именам
+	var names []string
+	for _, l := range strings.Split(text, "\n") {
+		if strings.HasPrefix(l, "# TYPE ") {
+			names = append(names, strings.Fields(l)[2])
+		}
+	}
+	if strings.Join(names, ",") != "t_dur_seconds,t_fn,t_req_total,t_up" {
+		t.Errorf("metrics order %v", names)
+	}
+}
+
+func TestMetricsHistogramDefault(t *testing.T) {
+	var r Vmreg
+	r.Histogram("t_default_seconds", "", nil).Observe(100)
+	text := testScrape(t, &r)
+	testLines(t, text,
+		`t_default_seconds_bucket{le="0.005"} 0`,
+		`t_default_seconds_bucket{le="10"} 0`,
+		`t_default_seconds_bucket{le="+Inf"} 1`,
+		"t_default_seconds_sum 100",
+		"t_default_seconds_count 1",
+	)
+	if n := strings.Count(text, "_bucket"); n != len(DefBuckets)+1 {
+		t.Errorf("buckets %d, want %d", n, len(DefBuckets)+1)
+	}
+}
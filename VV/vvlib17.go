@@ -0,0 +1,308 @@
+package vv
+
+// Здоровье и готовность приложения:
+// - HealthHandler (liveness) - процесс жив: время работы от Vapplt, очередь и последняя ошибка записи Vlogger,
+//   срок действия TLS-сертификата из ListenAndServeTLS и проверки ChkLive; отвечает 503 только если
+//   какая-то из них провалена (HsFail); истекший сертификат - предупреждение: перезапуск его не обновит
+// - ReadyHandler (readiness) - экземпляр готов принимать запросы: ping Dba с задержкой и проверки ChkReady;
+//   отвечает 503, если проверка провалена или экземпляр выводится из балансировки (SetDraining)
+// - ответ - JSON со статусом по каждой проверке; HsWarn - предупреждение, на код ответа не влияет
+// - проверки приложения регистрируются через AddCheck и выполняются параллельно с таймаутом Timeout
+// Vhealth - реестр проверок по умолчанию.
+//
+// Пример:
+//   vv.Vhealth.AddCheck("cache", vv.ChkReady, func(ctx context.Context) error { return cache.Ping(ctx) })
+//   http.Handle("/healthz", vv.HealthHandler())
+//   http.Handle("/readyz", vv.ReadyHandler())
+//   ... при остановке: vv.Vhealth.SetDraining(true); пауза, пока балансировщик снимет экземпляр; srv.Shutdown(ctx)
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// статусы проверок
+const (
+	HsOK       = "ok"       // в порядке
+	HsWarn     = "warn"     // предупреждение: на код ответа не влияет
+	HsFail     = "fail"     // проверка провалена: ответ 503
+	HsDraining = "draining" // экземпляр выводится из балансировки (только общий статус ReadyHandler)
+)
+
+// виды проверок приложения
+const (
+	ChkLive  = 1 // проверка живости: HealthHandler
+	ChkReady = 2 // проверка готовности: ReadyHandler
+)
+
+// Vchk функция проверки приложения: nil - в порядке, ошибка - проверка провалена
+type Vchk func(ctx context.Context) error
+
+// Vhres результат одной проверки
+type Vhres struct {
+	Status  string                 `json:"status"`               // HsOK, HsWarn, HsFail
+	Latency float64                `json:"latency_ms,omitempty"` // длительность проверки, мс
+	Error   string                 `json:"error,omitempty"`      // ошибка или причина предупреждения
+	Info    map[string]interface{} `json:"info,omitempty"`       // подробности
+}
+
+// Vhrep ответ HealthHandler / ReadyHandler
+type Vhrep struct {
+	Status string           `json:"status"` // общий статус: HsOK, HsFail, HsDraining
+	Time   time.Time        `json:"time"`   // время ответа
+	Start  time.Time        `json:"start"`  // время запуска приложения (Vapplt)
+	Uptime string           `json:"uptime"` // время работы
+	UpSec  float64          `json:"uptime_sec"`
+	Checks map[string]Vhres `json:"checks"` // проверки по именам
+}
+
+// Vhc реестр проверок здоровья; нулевое значение готово к работе
+type Vhc struct {
+	Timeout time.Duration // таймаут всех проверок одного запроса (0 - 5 сек)
+
+	mu       sync.RWMutex
+	checks   map[string]vhchk // проверки приложения по именам
+	draining atomic.Bool      // экземпляр выводится из балансировки
+}
+
+// vhchk проверка приложения
+type vhchk struct {
+	kind int  // ChkLive, ChkReady
+	fn   Vchk // функция проверки
+}
+
+// Vhealth реестр проверок здоровья по умолчанию
+var Vhealth Vhc
+
+// TLS-сертификат, загруженный ListenAndServeTLS
+var vtls atomic.Pointer[x509.Certificate]
+
+// AddCheck -----------------------------------------------------------------------------
+// добавление (замена) проверки приложения name вида kind (ChkLive, ChkReady)
+func (h *Vhc) AddCheck(name string, kind int, fn Vchk) {
+	h.mu.Lock()
+	if h.checks == nil {
+		h.checks = make(map[string]vhchk)
+	}
+	h.checks[name] = vhchk{kind: kind, fn: fn}
+	h.mu.Unlock()
+}
+
+// DelCheck -----------------------------------------------------------------------------
+// удаление проверки приложения name
+func (h *Vhc) DelCheck(name string) {
+	h.mu.Lock()
+	delete(h.checks, name)
+	h.mu.Unlock()
+}
+
+// SetDraining -----------------------------------------------------------------------------
+// вывод экземпляра из балансировки (true): ReadyHandler отвечает 503, HealthHandler - как прежде
+func (h *Vhc) SetDraining(on bool) {
+	h.draining.Store(on)
+	Vlogger.Vlog(0, "Health: draining "+Vifs(on, "on", "off"), Einf)
+}
+
+// Draining -----------------------------------------------------------------------------
+func (h *Vhc) Draining() bool { return h.draining.Load() }
+
+// Live -----------------------------------------------------------------------------
+// отчет о живости приложения
+func (h *Vhc) Live(ctx context.Context) Vhrep {
+	rep := h.run(ctx, ChkLive)
+	rep.Checks["log"] = vhLog()
+	if c := vtls.Load(); c != nil {
+		rep.Checks["tls"] = vhTLS(c)
+	}
+	rep.Status = vhStatus(rep.Checks)
+	return rep
+}
+
+// Ready -----------------------------------------------------------------------------
+// отчет о готовности приложения
+func (h *Vhc) Ready(ctx context.Context) Vhrep {
+	rep := h.run(ctx, ChkReady)
+	rep.Status = vhStatus(rep.Checks)
+	if h.draining.Load() {
+		rep.Status = HsDraining
+	}
+	return rep
+}
+
+// run -----------------------------------------------------------------------------
+// отчет с результатами проверок приложения вида kind (параллельно, с таймаутом); для готовности - и ping Dba
+func (h *Vhc) run(ctx context.Context, kind int) Vhrep {
+	now := time.Now()
+	rep := Vhrep{Time: now, Start: Vapplt, Uptime: now.Sub(Vapplt).Round(time.Second).String(),
+		UpSec: now.Sub(Vapplt).Seconds(), Checks: make(map[string]Vhres)}
+
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	fns := make(map[string]Vchk)
+	if kind == ChkReady && Dba != nil {
+		fns["db"] = Dba.PingContext
+	}
+	h.mu.RLock()
+	for name, c := range h.checks {
+		if c.kind == kind {
+			fns[name] = c.fn
+		}
+	}
+	h.mu.RUnlock()
+
+	names := make([]string, 0, len(fns))
+	for name := range fns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	res := make([]Vhres, len(names))
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, fn Vchk) {
+			defer wg.Done()
+			res[i] = vhDo(ctx, fn)
+		}(i, fns[name])
+	}
+	wg.Wait()
+
+	for i, name := range names {
+		rep.Checks[name] = res[i]
+	}
+	return rep
+}
+
+// vhDo -----------------------------------------------------------------------------
+// выполнение одной проверки: не дольше ctx, с перехватом паники
+func vhDo(ctx context.Context, fn Vchk) Vhres {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- errors.New("panic in check")
+			}
+		}()
+		done <- fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := Vhres{Status: HsOK, Latency: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		res.Status, res.Error = HsFail, err.Error()
+	}
+	return res
+}
+
+// vhLog -----------------------------------------------------------------------------
+// состояние лога Vlogger: очередь заполнена на 90% или недавняя (5 мин) ошибка записи - предупреждение
+func vhLog() Vhres {
+	st := Vlogger.Stats()
+	res := Vhres{Status: HsOK, Info: map[string]interface{}{
+		"queue": st.Queue, "cap": st.Cap, "dropped": st.Dropped, "spilled": st.Spilled,
+	}}
+	if st.Cap > 0 && st.Queue*10 >= st.Cap*9 {
+		res.Status, res.Error = HsWarn, "log queue is almost full"
+	}
+	if t, err := Vlogger.LastError(); err != nil {
+		res.Info["last_error"], res.Info["last_error_time"] = err.Error(), t
+		if time.Since(t) < 5*time.Minute {
+			res.Status, res.Error = HsWarn, err.Error()
+		}
+	}
+	return res
+}
+
+// vhTLS -----------------------------------------------------------------------------
+// срок действия TLS-сертификата: истек или истекает в ближайшие 14 дней - предупреждение
+// - истекший сертификат не проваливает живость: иначе оркестратор без толку перезапускал бы процесс
+func vhTLS(c *x509.Certificate) Vhres {
+	left := time.Until(c.NotAfter)
+	res := Vhres{Status: HsOK, Info: map[string]interface{}{
+		"subject": c.Subject.String(), "not_after": c.NotAfter, "days_left": int(left.Hours() / 24),
+	}}
+	switch {
+	case left <= 0:
+		res.Status, res.Error = HsWarn, "certificate expired"
+	case left < 14*24*time.Hour:
+		res.Status, res.Error = HsWarn, "certificate expires in "+strconv.Itoa(int(left.Hours()/24))+" days"
+	}
+	return res
+}
+
+// vhStatus -----------------------------------------------------------------------------
+// общий статус: HsFail, если провалена хоть одна проверка
+func vhStatus(checks map[string]Vhres) string {
+	for _, c := range checks {
+		if c.Status == HsFail {
+			return HsFail
+		}
+	}
+	return HsOK
+}
+
+// vtlsCert -----------------------------------------------------------------------------
+// запоминание сертификата, загруженного ListenAndServeTLS, для проверки его срока
+func vtlsCert(cert tls.Certificate) {
+	if len(cert.Certificate) == 0 {
+		return
+	}
+	c, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return
+	}
+	vtls.Store(c)
+}
+
+// Handler -----------------------------------------------------------------------------
+// web server: JSON-отчет; live - о живости (иначе о готовности); при HsFail/HsDraining - код 503
+func (h *Vhc) Handler(live bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rep Vhrep
+		if live {
+			rep = h.Live(r.Context())
+		} else {
+			rep = h.Ready(r.Context())
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if rep.Status != HsOK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(rep)
+	})
+}
+
+// HealthHandler -----------------------------------------------------------------------------
+// web server: живость приложения по реестру проверок Vhealth
+func HealthHandler() http.Handler {
+	return Vhealth.Handler(true)
+}
+
+// ReadyHandler -----------------------------------------------------------------------------
+// web server: готовность приложения по реестру проверок Vhealth
+func ReadyHandler() http.Handler {
+	return Vhealth.Handler(false)
+}
@@ -0,0 +1,219 @@
+package vv
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testHealth ответ обработчика h: код и разобранный JSON
+func testHealth(t *testing.T, h http.Handler) (int, Vhrep) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var rep Vhrep
+	if err := json.Unmarshal(w.Body.Bytes(), &rep); err != nil {
+		t.Fatalf("bad JSON %q: %v", w.Body.String(), err)
+	}
+	return w.Code, rep
+}
+
+// testLogErr ошибка записи лога Vlogger на время теста
+func testLogErr(t *testing.T, tm time.Time, err error) {
+	prev := Vlogger.lerr.Load()
+	Vlogger.lerr.Store(&vlerr{err: err, t: tm})
+	t.Cleanup(func() { Vlogger.lerr.Store(prev) })
+}
+
+func TestLastError(t *testing.T) {
+	vl := &Vlg{}
+	if tm, err := vl.LastError(); err != nil || !tm.IsZero() {
+		t.Errorf("LastError without errors = %v, %v", tm, err)
+	}
+	before := time.Now()
+	vl.fail("Sink ring:", errors.New("disk full"))
+	tm, err := vl.LastError()
+	if err == nil || err.Error() != "Sink ring: disk full" || tm.Before(before) {
+		t.Errorf("LastError = %v, %v", tm, err)
+	}
+}
+
+func TestHealthLog(t *testing.T) {
+	if res := vhLog(); res.Status != HsOK || res.Info["last_error"] != nil {
+		t.Errorf("no log errors: %+v", res)
+	}
+
+	testLogErr(t, time.Now().Add(-time.Minute), errors.New("disk full"))
+	if res := vhLog(); res.Status != HsWarn || res.Error != "disk full" {
+		t.Errorf("recent log error: %+v", res)
+	}
+
+	testLogErr(t, time.Now().Add(-time.Hour), errors.New("disk full"))
+	if res := vhLog(); res.Status != HsOK || res.Info["last_error"] != "disk full" {
+		t.Errorf("old log error: %+v, want ok with the error in info", res)
+	}
+}
+
+func TestHealthTLS(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		left time.Duration
+		want string
+	}{
+		{"valid", 90 * 24 * time.Hour, HsOK},
+		{"expiring", 3 * 24 * time.Hour, HsWarn},
+		{"expired", -time.Hour, HsWarn}, // перезапуск не поможет: живость не проваливается
+	} {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: "example.com"}, NotAfter: time.Now().Add(c.left)}
+		if res := vhTLS(cert); res.Status != c.want {
+			t.Errorf("%s: status %q, want %q (%s)", c.name, res.Status, c.want, res.Error)
+		}
+	}
+
+	prev := vtls.Load()
+	vtls.Store(&x509.Certificate{NotAfter: time.Now().Add(-time.Hour)})
+	defer vtls.Store(prev)
+	code, rep := testHealth(t, (&Vhc{}).Handler(true))
+	if code != http.StatusOK || rep.Status != HsOK || rep.Checks["tls"].Error != "certificate expired" {
+		t.Errorf("expired certificate: %d %+v", code, rep)
+	}
+}
+
+func TestHealthStatus(t *testing.T) {
+	fail := func(ctx context.Context) error { return errors.New("down") }
+	okfn := func(ctx context.Context) error { return nil }
+
+	for _, c := range []struct {
+		name   string
+		live   bool
+		checks map[string]Vchk
+		kind   int
+		drain  bool
+		code   int
+		status string
+	}{
+		{"live ok", true, map[string]Vchk{"app": okfn}, ChkLive, false, 200, HsOK},
+		{"live fail", true, map[string]Vchk{"app": fail}, ChkLive, false, 503, HsFail},
+		{"live ignores ready checks", true, map[string]Vchk{"app": fail}, ChkReady, false, 200, HsOK},
+		{"live ignores draining", true, nil, ChkLive, true, 200, HsOK},
+		{"ready ok", false, map[string]Vchk{"app": okfn}, ChkReady, false, 200, HsOK},
+		{"ready fail", false, map[string]Vchk{"a": okfn, "b": fail}, ChkReady, false, 503, HsFail},
+		{"ready ignores live checks", false, map[string]Vchk{"app": fail}, ChkLive, false, 200, HsOK},
+		{"ready draining", false, map[string]Vchk{"app": okfn}, ChkReady, true, 503, HsDraining},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			h := &Vhc{}
+			for name, fn := range c.checks {
+				h.AddCheck(name, c.kind, fn)
+			}
+			h.draining.Store(c.drain)
+
+			code, rep := testHealth(t, h.Handler(c.live))
+			if code != c.code || rep.Status != c.status {
+				t.Errorf("%d %q, want %d %q", code, rep.Status, c.code, c.status)
+			}
+			if _, ok := rep.Checks["log"]; ok != c.live {
+				t.Errorf("log check present = %v", ok)
+			}
+			for name := range c.checks {
+				if _, ok := rep.Checks[name]; ok != (c.live == (c.kind == ChkLive)) {
+					t.Errorf("check %s present = %v", name, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthJSON(t *testing.T) {
+	testLogErr(t, time.Now(), errors.New("disk full")) // лог - предупреждение
+	h := &Vhc{}
+	h.AddCheck("cache", ChkLive, func(ctx context.Context) error { return nil })
+	h.AddCheck("queue", ChkLive, func(ctx context.Context) error { return errors.New("stuck") })
+
+	w := httptest.NewRecorder()
+	h.Handler(true).ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	if cc := w.Header().Get("Cache-Control"); cc != "no-store" {
+		t.Errorf("Cache-Control = %q", cc)
+	}
+
+	var body struct {
+		Status string  `json:"status"`
+		UpSec  float64 `json:"uptime_sec"`
+		Checks map[string]struct {
+			Status string                 `json:"status"`
+			Error  *string                `json:"error"`
+			Info   map[string]interface{} `json:"info"`
+		} `json:"checks"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Status != HsFail || body.UpSec <= 0 {
+		t.Errorf("status %q uptime %v", body.Status, body.UpSec)
+	}
+	for _, c := range []struct {
+		name   string
+		status string
+		err    string
+	}{
+		{"cache", HsOK, ""},
+		{"log", HsWarn, "disk full"},
+		{"queue", HsFail, "stuck"},
+	} {
+		got := body.Checks[c.name]
+		if got.Status != c.status {
+			t.Errorf("%s: status %q, want %q", c.name, got.Status, c.status)
+		}
+		switch {
+		case c.err == "" && got.Error != nil: // omitempty: у успешной проверки поля error нет
+			t.Errorf("%s: error %q, want none", c.name, *got.Error)
+		case c.err != "" && (got.Error == nil || *got.Error != c.err):
+			t.Errorf("%s: error %v, want %q", c.name, got.Error, c.err)
+		}
+	}
+	if body.Checks["log"].Info["last_error"] != "disk full" {
+		t.Errorf("log info %v", body.Checks["log"].Info)
+	}
+}
+
+func TestHealthTimeout(t *testing.T) {
+	h := &Vhc{Timeout: 50 * time.Millisecond}
+	h.AddCheck("slow", ChkReady, func(ctx context.Context) error {
+		time.Sleep(time.Second) // проверка не следит за ctx
+		return nil
+	})
+	h.AddCheck("ctx", ChkReady, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	h.AddCheck("panic", ChkReady, func(ctx context.Context) error { panic("boom") })
+	h.AddCheck("fast", ChkReady, func(ctx context.Context) error { return nil })
+
+	start := time.Now()
+	code, rep := testHealth(t, h.Handler(false))
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("handler took %v with a 50ms timeout", d)
+	}
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("code %d, want 503", code)
+	}
+	for name, want := range map[string]string{
+		"slow":  context.DeadlineExceeded.Error(),
+		"ctx":   context.DeadlineExceeded.Error(),
+		"panic": "panic in check",
+		"fast":  "",
+	} {
+		if got := rep.Checks[name].Error; got != want {
+			t.Errorf("%s: error %q, want %q", name, got, want)
+		}
+	}
+}
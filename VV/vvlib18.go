@@ -0,0 +1,263 @@
+package vv
+
+// Статический файл с учетом http-запроса:
+// - StatToHttpReq - как StatToHttp, но получает сам запрос r и поэтому умеет условные запросы и Range
+// - валидаторы: Last-Modified из ModTime файла и ETag из хеша содержимого (sha256); для файлов vfsgen хеш
+//   посчитан при генерации, для остальных считается потоком и запоминается по имени, размеру и времени изменения
+// - файл отдается потоком (см. vvlib19.go), диапазоны читаются через Seek
+// - If-None-Match / If-Modified-Since совпали - ответ 304 без тела
+// - Range: один диапазон - 206 с Content-Range, несколько - 206 multipart/byteranges; работает для любой
+//   http.FileSystem, включая сгенерированную vfsgen (см. vvlib2.go)
+// - файл сгенерированной vfsgen файловой системы, хранящийся сжатым (метод GzipBytes), отдается клиенту,
+//   принимающему gzip (Accept-Encoding), как есть: сжатые байты с Content-Encoding: gzip, без распаковки
+//   на сервере; остальным клиентам и на запросы с Range - распакованным; в обоих случаях Vary: Accept-Encoding
+// Сами условия и диапазоны разбирает http.ServeContent, этот файл дает ему содержимое и валидаторы.
+//
+// Пример:
+//   http.HandleFunc("/app.js", func(w http.ResponseWriter, r *http.Request) {
+//       vv.StatToHttpReq(w, r, assets, "/app.js", "application/javascript")
+//   })
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vgzipper файл, хранящийся сжатым gzip (vfsgen۰CompressedFileInfo, см. vvlib2.go)
+type vgzipper interface {
+	GzipBytes() []byte
+}
+
+// StatToHttpReq -----------------------------------------------------------------------------
+// web server: статический файл -> http-ответ на запрос r с учетом условных заголовков и Range
+// - id обработки берется из r.Context() (см. PidHandler)
+// - ftype - тип содержимого файла ("" - по расширению имени или содержимому)
+func StatToHttpReq(w http.ResponseWriter, r *http.Request, assets http.FileSystem, fname string, ftype string) error {
+	return statToHttpReq(w, r, assets, fname, ftype, true)
+}
+
+// statToHttpReq -----------------------------------------------------------------------------
+// StatToHttpReq; sentLog - писать в лог отданный файл (Vstat сам пишет в лог каждый запрос, см. vvlib20.go)
+// - ошибки пишутся в лог всегда
+func statToHttpReq(w http.ResponseWriter, r *http.Request, assets http.FileSystem, fname string, ftype string, sentLog bool) error {
+	pid := GetpidCtx(r.Context()) // id обработки
+	cw := &vstatw{ResponseWriter: w}
+
+	start, status := time.Now(), vmStatusError // для метрик (см. vvlib16.go)
+	defer func() { vmStatic("StatToHttpReq", status, cw.n, start) }()
+
+	file, fstat, rd, err := statOpen(pid, assets, fname)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	h := w.Header()
+	if ftype != "" {
+		h.Set("Content-Type", ftype)
+	}
+
+	if gz, ok := file.(vgzipper); ok {
+		h.Add("Vary", "Accept-Encoding") // ответ зависит от Accept-Encoding: кешам нельзя его смешивать
+		if r.Header.Get("Range") == "" && acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			err = statGzip(cw, r, fstat.Name(), fstat.ModTime(), gz.GzipBytes())
+			if err != nil {
+				Vlogger.Vlog(pid, "Sent to http error: "+fname+": "+err.Error(), Eerr)
+				return err
+			}
+			status = vmStatusOK
+			if sentLog {
+				Vlogger.Vlog(pid, fname+" was sent to http-reply gzipped ("+strconv.Itoa(cw.code)+")", Einf)
+			}
+			return nil
+		}
+	}
+
+	sum := fileFingerprint(file) // хеш содержимого: у файлов vfsgen он посчитан при генерации (см. vvlib21.go)
+	if sum == "" {
+		if sum, err = statHash(assets, rd, fname, fstat); err != nil {
+			Vlogger.Vlog(pid, "File read error: "+fname+": "+err.Error(), Eerr)
+			return err
+		}
+	}
+	h.Set("ETag", `"`+sum[:32]+`"`)
+
+	http.ServeContent(cw, r, fstat.Name(), fstat.ModTime(), rd) // файл отдается потоком, Range - через Seek
+
+	if cw.err != nil {
+		Vlogger.Vlog(pid, "Sent to http error: "+fname+": "+cw.err.Error(), Eerr)
+		return cw.err
+	}
+	status = vmStatusOK
+	if sentLog {
+		Vlogger.Vlog(pid, fname+" was sent to http-reply ("+strconv.Itoa(cw.code)+")", Einf)
+	}
+	return nil
+}
+
+// statGzip -----------------------------------------------------------------------------
+// отдача сжатых байт gz файла name как есть, с Content-Encoding: gzip
+// - Content-Type задается до ServeContent: иначе он определил бы тип по сжатым байтам
+func statGzip(cw *vstatw, r *http.Request, name string, modtime time.Time, gz []byte) error {
+	h := cw.Header()
+	if h.Get("Content-Type") == "" {
+		ctype := mime.TypeByExtension(path.Ext(name))
+		if ctype == "" { // по расширению не понять - смотрим начало распакованного содержимого
+			var head []byte
+			if gr, err := gzip.NewReader(bytes.NewReader(gz)); err == nil {
+				head, _ = io.ReadAll(io.LimitReader(gr, 512))
+			}
+			ctype = http.DetectContentType(head)
+		}
+		h.Set("Content-Type", ctype)
+	}
+	h.Set("Content-Encoding", "gzip")
+	h.Set("ETag", statEtag(gz)) // у сжатого варианта свой ETag
+
+	http.ServeContent(cw, r, name, modtime, bytes.NewReader(gz))
+	return cw.err
+}
+
+// acceptsGzip -----------------------------------------------------------------------------
+// принимает ли клиент gzip по заголовку Accept-Encoding (с учетом q=0 и *)
+func acceptsGzip(ae string) bool {
+	gz, star := -1.0, -1.0 // q для gzip и для *, -1 - не указаны
+	for _, part := range strings.Split(ae, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				q = f
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "gzip", "x-gzip":
+			gz = q
+		case "*":
+			star = q
+		}
+	}
+	if gz >= 0 {
+		return gz > 0
+	}
+	return star > 0
+}
+
+// statEtag -----------------------------------------------------------------------------
+// ETag по содержимому файла: первые 16 байт sha256 в hex, в кавычках
+func statEtag(b []byte) string {
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// кеш хешей содержимого файлов
+var (
+	vhashmu sync.Mutex
+	vhashes = make(map[vhkey]string)
+)
+
+// vhkey ключ кеша хешей: файловая система, имя, размер и время изменения файла
+type vhkey struct {
+	fs   interface{} // файловая система (см. statFsid)
+	name string
+	size int64
+	mod  int64
+}
+
+// max записей в кеше хешей: при переполнении кеш очищается
+const vhashMax = 4096
+
+// statHash -----------------------------------------------------------------------------
+// sha256 содержимого файла rd в hex: считается потоком, rd возвращается в начало
+// - хеш кешируется по файловой системе assets, имени, размеру и времени изменения файла
+// - без кеша: файлы с нулевым временем изменения (см. Options.ZeroModTime) и неразличимые файловые системы
+func statHash(assets http.FileSystem, rd io.ReadSeeker, fname string, fstat os.FileInfo) (string, error) {
+	key := vhkey{fs: statFsid(assets), name: fname, size: fstat.Size(), mod: fstat.ModTime().UnixNano()}
+	cache := key.fs != nil && !fstat.ModTime().IsZero()
+
+	if cache {
+		vhashmu.Lock()
+		sum, ok := vhashes[key]
+		vhashmu.Unlock()
+		if ok {
+			return sum, nil
+		}
+	}
+
+	hs := sha256.New()
+	if _, err := io.Copy(hs, rd); err != nil {
+		return "", err
+	}
+	if _, err := rd.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(hs.Sum(nil))
+	if !cache {
+		return sum, nil
+	}
+
+	vhashmu.Lock()
+	if len(vhashes) >= vhashMax {
+		vhashes = make(map[vhkey]string)
+	}
+	vhashes[key] = sum
+	vhashmu.Unlock()
+	return sum, nil
+}
+
+// statFsid -----------------------------------------------------------------------------
+// значение, различающее файловые системы в ключе кеша (nil - различить нельзя)
+// - указатели и сравнимые значения (http.Dir) - сами по себе, map - тип и адрес
+func statFsid(assets http.FileSystem) interface{} {
+	v := reflect.ValueOf(assets)
+	switch {
+	case !v.IsValid():
+		return nil
+	case v.Kind() == reflect.Map:
+		return v.Type().String() + "@" + strconv.FormatUint(uint64(v.Pointer()), 16)
+	case v.Comparable():
+		return assets
+	}
+	return nil
+}
+
+// vstatw http-ответ со счетом отправленных байт, кодом ответа и первой ошибкой записи
+type vstatw struct {
+	http.ResponseWriter
+	code int   // код ответа
+	n    int   // отправлено байт тела
+	err  error // первая ошибка записи
+}
+
+// WriteHeader -----------------------------------------------------------------------------
+func (cw *vstatw) WriteHeader(code int) {
+	if cw.code == 0 {
+		cw.code = code
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+// Write -----------------------------------------------------------------------------
+func (cw *vstatw) Write(p []byte) (int, error) {
+	if cw.code == 0 {
+		cw.code = http.StatusOK
+	}
+	n, err := cw.ResponseWriter.Write(p)
+	cw.n += n
+	if err != nil && cw.err == nil {
+		cw.err = err
+	}
+	return n, err
+}
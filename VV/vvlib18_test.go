@@ -0,0 +1,295 @@
+package vv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// testMtime время изменения файлов тестовых файловых систем
+var testMtime = time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+// testGzFS файловая система: файлы fs и файлы gz, хранящиеся сжатыми, как в сгенерированном vfsgen коде
+type testGzFS struct {
+	fs fstest.MapFS
+	gz map[string]*testGzFile
+}
+
+// testGzFile сжатый файл testGzFS
+type testGzFile struct {
+	name string
+	data []byte // распакованное содержимое
+	gz   []byte // сжатое содержимое
+	fp   string // отпечаток (метод Fingerprint, "" - нет метода)
+}
+
+// testFS файловая система с файлами files и сжатыми файлами gzs (имена без "/" в начале)
+func testFS(files map[string]string, gzs map[string]string) *testGzFS {
+	fsys := &testGzFS{fs: fstest.MapFS{}, gz: make(map[string]*testGzFile)}
+	for name, data := range files {
+		fsys.fs[name] = &fstest.MapFile{Data: []byte(data), ModTime: testMtime}
+	}
+	for name, data := range gzs {
+		var b bytes.Buffer
+		zw := gzip.NewWriter(&b)
+		zw.Write([]byte(data))
+		zw.Close()
+		fsys.gz["/"+name] = &testGzFile{name: name, data: []byte(data), gz: b.Bytes()}
+	}
+	return fsys
+}
+
+func (fsys *testGzFS) Open(name string) (http.File, error) {
+	if f, ok := fsys.gz[name]; ok {
+		if f.fp != "" {
+			return &testGzFpHandle{testGzHandle{f, bytes.NewReader(f.data)}}, nil
+		}
+		return &testGzHandle{f, bytes.NewReader(f.data)}, nil
+	}
+	return http.FS(fsys.fs).Open(name)
+}
+
+// testGzHandle открытый сжатый файл
+type testGzHandle struct {
+	f *testGzFile
+	*bytes.Reader
+}
+
+func (h *testGzHandle) Close() error                       { return nil }
+func (h *testGzHandle) Readdir(int) ([]os.FileInfo, error) { return nil, errors.New("not a directory") }
+func (h *testGzHandle) Stat() (os.FileInfo, error)         { return h, nil }
+func (h *testGzHandle) GzipBytes() []byte                  { return h.f.gz }
+func (h *testGzHandle) Name() string                       { return h.f.name }
+func (h *testGzHandle) Size() int64                        { return int64(len(h.f.data)) }
+func (h *testGzHandle) Mode() os.FileMode                  { return 0444 }
+func (h *testGzHandle) ModTime() time.Time                 { return testMtime }
+func (h *testGzHandle) IsDir() bool                        { return false }
+func (h *testGzHandle) Sys() interface{}                   { return nil }
+
+// testGzFpHandle открытый сжатый файл с отпечатком
+type testGzFpHandle struct {
+	testGzHandle
+}
+
+func (h *testGzFpHandle) Fingerprint() string { return h.f.fp }
+
+// testSha256 sha256 в hex
+func testSha256(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// testReq ответ StatToHttpReq на GET fname с заголовками hdr ("имя", "значение", ...)
+func testReq(t *testing.T, assets http.FileSystem, fname string, hdr ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest("GET", fname, nil)
+	for i := 0; i+1 < len(hdr); i += 2 {
+		r.Header.Set(hdr[i], hdr[i+1])
+	}
+	w := httptest.NewRecorder()
+	if err := StatToHttpReq(w, r, assets, fname, ""); err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestStatToHttpReq(t *testing.T) {
+	const body = "0123456789abcdef"
+	assets := testFS(map[string]string{"app.js": body}, nil)
+
+	w := testReq(t, assets, "/app.js")
+	etag := `"` + testSha256(body)[:32] + `"`
+	if w.Code != 200 || w.Body.String() != body {
+		t.Fatalf("GET: %d %q", w.Code, w.Body)
+	}
+	if got := w.Header().Get("ETag"); got != etag {
+		t.Errorf("ETag = %s, want %s", got, etag)
+	}
+	if got := w.Header().Get("Last-Modified"); got != testMtime.Format(http.TimeFormat) {
+		t.Errorf("Last-Modified = %s", got)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/javascript") {
+		t.Errorf("Content-Type = %s, want by extension", ct)
+	}
+
+	for _, c := range []struct {
+		name string
+		hdr  []string
+		code int
+		body string
+	}{
+		{"If-None-Match", []string{"If-None-Match", etag}, 304, ""},
+		{"If-None-Match stale", []string{"If-None-Match", `"stale"`}, 200, body},
+		{"If-Modified-Since", []string{"If-Modified-Since", testMtime.Format(http.TimeFormat)}, 304, ""},
+		{"If-Modified-Since old", []string{"If-Modified-Since", testMtime.Add(-time.Hour).Format(http.TimeFormat)}, 200, body},
+		{"Range", []string{"Range", "bytes=2-5"}, 206, "2345"},
+		{"Range suffix", []string{"Range", "bytes=-3"}, 206, "def"},
+		{"If-Range stale", []string{"Range", "bytes=2-5", "If-Range", `"stale"`}, 200, body},
+		{"Range unsatisfiable", []string{"Range", "bytes=100-"}, 416, ""},
+	} {
+		w := testReq(t, assets, "/app.js", c.hdr...)
+		if w.Code != c.code || (c.body != "" && w.Body.String() != c.body) {
+			t.Errorf("%s: %d %q, want %d %q", c.name, w.Code, w.Body, c.code, c.body)
+		}
+	}
+}
+
+func TestStatToHttpReqMultiRange(t *testing.T) {
+	assets := testFS(map[string]string{"a.txt": "0123456789"}, nil)
+	w := testReq(t, assets, "/a.txt", "Range", "bytes=0-1,8-9")
+	if w.Code != 206 {
+		t.Fatalf("code %d, want 206", w.Code)
+	}
+	mt, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil || mt != "multipart/byteranges" {
+		t.Fatalf("Content-Type %s, %v", mt, err)
+	}
+
+	var parts []string
+	mr := multipart.NewReader(w.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(p)
+		parts = append(parts, string(b))
+	}
+	if strings.Join(parts, ",") != "01,89" {
+		t.Errorf("parts = %q, want [01 89]", parts)
+	}
+}
+
+func TestStatToHttpReqMissing(t *testing.T) {
+	assets := testFS(map[string]string{"sub/a.txt": "a"}, nil)
+	for _, name := range []string{"/none.txt", "/sub"} {
+		r := httptest.NewRequest("GET", name, nil)
+		if err := StatToHttpReq(httptest.NewRecorder(), r, assets, name, ""); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+}
+
+func TestStatToHttpReqGzip(t *testing.T) {
+	body := strings.Repeat("body { color: red }\n", 100)
+	assets := testFS(nil, map[string]string{"site.css": body})
+	gz := assets.gz["/site.css"].gz
+
+	w := testReq(t, assets, "/site.css", "Accept-Encoding", "br, gzip")
+	if w.Code != 200 || !bytes.Equal(w.Body.Bytes(), gz) {
+		t.Fatalf("gzip: %d, body is not the stored gzip bytes", w.Code)
+	}
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("gzip: headers %v", w.Header())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/css") {
+		t.Errorf("gzip: Content-Type = %s, want text/css", ct)
+	}
+	gzEtag := w.Header().Get("ETag")
+
+	for _, c := range []struct {
+		name string
+		hdr  []string
+		code int
+		body string
+	}{
+		{"no Accept-Encoding", nil, 200, body},
+		{"gzip;q=0", []string{"Accept-Encoding", "gzip;q=0, *"}, 200, body},
+		{"Range", []string{"Accept-Encoding", "gzip", "Range", "bytes=0-3"}, 206, body[:4]},
+		{"If-None-Match gzip", []string{"Accept-Encoding", "gzip", "If-None-Match", gzEtag}, 304, ""},
+	} {
+		w := testReq(t, assets, "/site.css", c.hdr...)
+		if w.Code != c.code || w.Body.String() != c.body {
+			t.Errorf("%s: %d %q..., want %d", c.name, w.Code, w.Body.String()[:min(w.Body.Len(), 20)], c.code)
+		}
+		if w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%s: unexpected Content-Encoding", c.name)
+		}
+		if w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%s: Vary = %q", c.name, w.Header().Get("Vary"))
+		}
+		if etag := w.Header().Get("ETag"); c.code == 200 && etag == gzEtag {
+			t.Errorf("%s: identity response has the ETag of the gzip response", c.name)
+		}
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	for _, c := range []struct {
+		ae   string
+		want bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"GZIP", true},
+		{"x-gzip", true},
+		{"deflate, gzip;q=0.5", true},
+		{"gzip;q=0", false},
+		{"gzip; q=0.0", false},
+		{"*", true},
+		{"*;q=0", false},
+		{"gzip;q=0, *", false},
+		{"br, *;q=0.1", true},
+		{"identity", false},
+	} {
+		if got := acceptsGzip(c.ae); got != c.want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", c.ae, got, c.want)
+		}
+	}
+}
+
+func TestStatHashCache(t *testing.T) {
+	// одинаковые имя, размер и время изменения в разных файловых системах: кеш не должен их смешивать
+	a := testFS(map[string]string{"app.js": "aaaa"}, nil)
+	b := testFS(map[string]string{"app.js": "bbbb"}, nil)
+	for _, c := range []struct {
+		assets http.FileSystem
+		body   string
+	}{{a, "aaaa"}, {b, "bbbb"}, {a, "aaaa"}} {
+		w := testReq(t, c.assets, "/app.js")
+		if etag := w.Header().Get("ETag"); etag != `"`+testSha256(c.body)[:32]+`"` {
+			t.Errorf("%s: ETag %s of another filesystem", c.body, etag)
+		}
+	}
+
+	// без времени изменения файл может смениться незаметно для ключа кеша: хеш каждый раз считается заново
+	z := testFS(map[string]string{"app.js": "cccc"}, nil)
+	z.fs["app.js"].ModTime = time.Time{}
+	testReq(t, z, "/app.js")
+	z.fs["app.js"].Data = []byte("dddd")
+	if etag := testReq(t, z, "/app.js").Header().Get("ETag"); etag != `"`+testSha256("dddd")[:32]+`"` {
+		t.Errorf("zero mod time: stale ETag %s", etag)
+	}
+
+	for _, c := range []struct {
+		assets http.FileSystem
+		want   bool
+	}{
+		{http.Dir("/tmp"), true},
+		{a, true},
+		{http.FS(fstest.MapFS{}), false}, // map внутри структуры: различить нельзя
+		{nil, false},
+	} {
+		if got := statFsid(c.assets) != nil; got != c.want {
+			t.Errorf("statFsid(%T) cacheable = %v, want %v", c.assets, got, c.want)
+		}
+	}
+	if statFsid(a) == statFsid(b) {
+		t.Error("statFsid: different filesystems have the same id")
+	}
+}
@@ -0,0 +1,179 @@
+package vv
+
+// Потоковая отдача статических файлов:
+// - файл копируется кусками по 32 КБ, а не читается в буфер размером с файл одним Read:
+//   память не зависит от размера файла, короткие чтения дочитываются
+// - файл, прочитанный не до конца заявленного размера, - ошибка, а не молча обрезанное содержимое
+// - функции возвращают настоящие ошибки (открытия, чтения, записи, отмены ctx) и nil при успехе
+// - файл сгенерированной vfsgen файловой системы, хранящийся сжатым (GzipBytes), читается через собственный
+//   распаковщик с Seek (vgzseek), а не через Read сгенерированного файла: в файлах, сгенерированных до
+//   исправления шаблона (vvlib2.go), Read и Seek сжатого файла не работают
+// StatCopy - копирование файла в любой io.Writer; на нем работают StatToHttp и StatToByte.
+//
+// Пример:
+//   n, err := vv.StatCopy(ctx, f, assets, "/video.mp4")
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// размер куска при копировании файла
+const statChunk = 32 << 10
+
+// StatCopy -----------------------------------------------------------------------------
+// потоковое копирование статического файла fname в dst; возвращает число скопированных байт
+// - id обработки берется из ctx (см. CtxWithPid), отмена ctx прерывает копирование
+func StatCopy(ctx context.Context, dst io.Writer, assets http.FileSystem, fname string) (int64, error) {
+	pid := GetpidCtx(ctx) // id обработки
+
+	file, fstat, rd, err := statOpen(pid, assets, fname)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	return statCopy(ctx, pid, dst, rd, fstat.Size(), fname)
+}
+
+// statOpen -----------------------------------------------------------------------------
+// открытие статического файла: файл (закрыть после работы), его свойства и читатель с Seek
+// - ошибки пишутся в лог под id обработки pid
+func statOpen(pid uint64, assets http.FileSystem, fname string) (http.File, os.FileInfo, io.ReadSeeker, error) {
+	file, err := assets.Open(fname)
+	if err != nil {
+		Vlogger.Vlog(pid, "File open error: "+fname+": "+err.Error(), Eerr)
+		return nil, nil, nil, err
+	}
+
+	fstat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		Vlogger.Vlog(pid, "File stat getting error: "+fname+": "+err.Error(), Eerr)
+		return nil, nil, nil, err
+	}
+	if fstat.IsDir() {
+		file.Close()
+		err = errors.New("File is a directory: " + fname)
+		Vlogger.Vlog(pid, err.Error(), Eerr)
+		return nil, nil, nil, err
+	}
+
+	return file, fstat, statReader(file, fstat.Size()), nil
+}
+
+// statReader -----------------------------------------------------------------------------
+// читатель содержимого файла: для сжатого файла vfsgen - распаковщик с Seek, для остальных - сам файл
+func statReader(file http.File, size int64) io.ReadSeeker {
+	if gz, ok := file.(vgzipper); ok {
+		return &vgzseek{gz: gz.GzipBytes(), size: size}
+	}
+	return file
+}
+
+// statCopy -----------------------------------------------------------------------------
+// копирование src размером size (-1 - неизвестен) в dst кусками statChunk
+// - ошибки чтения, записи, отмены ctx и короткого файла пишутся в лог и возвращаются
+func statCopy(ctx context.Context, pid uint64, dst io.Writer, src io.Reader, size int64, fname string) (int64, error) {
+	if b, ok := dst.(*bytes.Buffer); ok && size > 0 {
+		b.Grow(int(size))
+	}
+
+	buf := make([]byte, statChunk)
+	var n int64
+	for {
+		if err := ctx.Err(); err != nil {
+			Vlogger.Vlog(pid, "File copy canceled: "+fname+": "+err.Error(), Ewrn)
+			return n, err
+		}
+
+		nr, rerr := src.Read(buf)
+		if nr > 0 {
+			nw, werr := dst.Write(buf[:nr])
+			n += int64(nw)
+			if werr == nil && nw < nr {
+				werr = io.ErrShortWrite
+			}
+			if werr != nil {
+				Vlogger.Vlog(pid, "File write error: "+fname+": "+werr.Error(), Eerr)
+				return n, werr
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			Vlogger.Vlog(pid, "File read error: "+fname+": "+rerr.Error(), Eerr)
+			return n, rerr
+		}
+	}
+
+	if size >= 0 && n != size {
+		err := fmt.Errorf("File read error: %s: got %d of %d bytes", fname, n, size)
+		Vlogger.Vlog(pid, err.Error(), Eerr)
+		return n, err
+	}
+	return n, nil
+}
+
+// vgzseek распаковщик сжатого файла vfsgen с Seek
+// - Seek вперед дочитывает распакованное содержимое до нужного места, назад - распаковывает заново с начала
+type vgzseek struct {
+	gz   []byte       // сжатое содержимое
+	size int64        // размер распакованного содержимого
+	gr   *gzip.Reader // распаковщик (nil - еще не открыт)
+	pos  int64        // позиция распаковщика
+	off  int64        // позиция чтения (после Seek)
+}
+
+// Read -----------------------------------------------------------------------------
+func (z *vgzseek) Read(p []byte) (int, error) {
+	if z.off >= z.size {
+		return 0, io.EOF
+	}
+
+	if z.gr == nil || z.off < z.pos { // распаковываем с начала
+		gr, err := gzip.NewReader(bytes.NewReader(z.gz))
+		if err != nil {
+			return 0, err
+		}
+		z.gr, z.pos = gr, 0
+	}
+	if z.off > z.pos { // пропускаем до позиции чтения
+		k, err := io.CopyN(io.Discard, z.gr, z.off-z.pos)
+		z.pos += k
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := z.gr.Read(p)
+	z.pos += int64(n)
+	z.off = z.pos
+	return n, err
+}
+
+// Seek -----------------------------------------------------------------------------
+func (z *vgzseek) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += z.off
+	case io.SeekEnd:
+		offset += z.size
+	default:
+		return z.off, errors.New("Seek: invalid whence: " + strconv.Itoa(whence))
+	}
+	if offset < 0 {
+		return z.off, errors.New("Seek: negative position")
+	}
+	z.off = offset
+	return z.off, nil
+}
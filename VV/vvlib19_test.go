@@ -0,0 +1,148 @@
+package vv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testShortFS файловая система, файлы которой заявляют размер больше настоящего
+type testShortFS struct {
+	http.FileSystem
+}
+
+type testShortFile struct {
+	http.File
+}
+
+type testShortInfo struct {
+	os.FileInfo
+}
+
+func (fsys testShortFS) Open(name string) (http.File, error) {
+	f, err := fsys.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return testShortFile{f}, nil
+}
+func (f testShortFile) Stat() (os.FileInfo, error) {
+	fi, err := f.File.Stat()
+	return testShortInfo{fi}, err
+}
+func (fi testShortInfo) Size() int64 { return fi.FileInfo.Size() + 10 }
+
+// testFailWriter приемник копирования, отказывающий после limit байт
+type testFailWriter struct {
+	limit int
+}
+
+func (w *testFailWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, errors.New("disk full")
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func TestStatCopy(t *testing.T) {
+	big := strings.Repeat("0123456789", 10000) // несколько кусков statChunk
+	assets := testFS(map[string]string{"big.txt": big, "empty.txt": ""}, map[string]string{"big.css": big})
+
+	for _, name := range []string{"/big.txt", "/big.css", "/empty.txt"} {
+		var buf bytes.Buffer
+		n, err := StatCopy(context.Background(), &buf, assets, name)
+		want := big
+		if name == "/empty.txt" {
+			want = ""
+		}
+		if err != nil || n != int64(len(want)) || buf.String() != want {
+			t.Errorf("%s: %d bytes, %v; want %d bytes", name, n, err, len(want))
+		}
+	}
+
+	b, err := StatToByte(assets, "/big.css")
+	if err != nil || string(b) != big {
+		t.Errorf("StatToByte: %d bytes, %v", len(b), err)
+	}
+}
+
+func TestStatCopyErrors(t *testing.T) {
+	assets := testFS(map[string]string{"big.txt": strings.Repeat("x", 100000), "dir/a.txt": "a"}, nil)
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, c := range []struct {
+		name   string
+		ctx    context.Context
+		dst    *testFailWriter
+		assets http.FileSystem
+		fname  string
+		want   error
+	}{
+		{"missing", context.Background(), &testFailWriter{1 << 30}, assets, "/none.txt", os.ErrNotExist},
+		{"directory", context.Background(), &testFailWriter{1 << 30}, assets, "/dir", nil},
+		{"canceled", canceled, &testFailWriter{1 << 30}, assets, "/big.txt", context.Canceled},
+		{"write error", context.Background(), &testFailWriter{1000}, assets, "/big.txt", nil},
+		{"short file", context.Background(), &testFailWriter{1 << 30}, testShortFS{assets}, "/big.txt", nil},
+	} {
+		_, err := StatCopy(c.ctx, c.dst, c.assets, c.fname)
+		if err == nil {
+			t.Errorf("%s: want error", c.name)
+			continue
+		}
+		if c.want != nil && !errors.Is(err, c.want) {
+			t.Errorf("%s: err %v, want %v", c.name, err, c.want)
+		}
+	}
+}
+
+// testSeeks чтения после Seek: ожидаемое содержимое по позициям content
+func testSeeks(t *testing.T, what string, rs io.ReadSeeker, content string) {
+	t.Helper()
+	size := int64(len(content))
+	for _, c := range []struct {
+		offset int64
+		whence int
+		pos    int64 // позиция после Seek
+	}{
+		{1000, io.SeekStart, 1000},
+		{-500, io.SeekCurrent, 510}, // назад от позиции после чтения 10 байт
+		{-10, io.SeekEnd, size - 10},
+		{0, io.SeekStart, 0},
+		{size + 5, io.SeekStart, size + 5},
+	} {
+		pos, err := rs.Seek(c.offset, c.whence)
+		if err != nil || pos != c.pos {
+			t.Fatalf("%s: Seek(%d, %d) = %d, %v; want %d", what, c.offset, c.whence, pos, err, c.pos)
+		}
+		buf := make([]byte, 10)
+		n, err := io.ReadFull(rs, buf)
+		want := content[min(pos, size):min(pos+10, size)]
+		if string(buf[:n]) != want || (want == "" && err != io.EOF) {
+			t.Errorf("%s: read at %d = %q, %v; want %q", what, pos, buf[:n], err, want)
+		}
+	}
+	if _, err := rs.Seek(-1, io.SeekStart); err == nil {
+		t.Errorf("%s: Seek to a negative position: want error", what)
+	}
+}
+
+func TestVgzseek(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; sb.Len() < 100000; i++ {
+		fmt.Fprintf(&sb, "line %d\n", i)
+	}
+	content := sb.String()
+	assets := testFS(nil, map[string]string{"big.txt": content})
+
+	testSeeks(t, "vgzseek", &vgzseek{gz: assets.gz["/big.txt"].gz, size: int64(len(content))}, content)
+}
@@ -0,0 +1,122 @@
+package vv
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestElevel(t *testing.T) {
+	// события, проходящие на каждом уровне: уровень пропускает свой тип и все более важные
+	for _, c := range []struct {
+		level int
+		want  string
+	}{
+		{Loff, ""},
+		{Lerr, "Ftl Err"},
+		{Lwrn, "Ftl Err Wrn"},
+		{Linf, "Ftl Err Wrn Inf"},
+		{Ldbg, "Ftl Err Wrn Inf Dbg"},
+	} {
+		var got []string
+		for _, etype := range []int{Eftl, Eerr, Ewrn, Einf, Edbg} {
+			if Elevel(etype, c.level) {
+				got = append(got, Etname(etype))
+			}
+		}
+		if strings.Join(got, " ") != c.want {
+			t.Errorf("level %d passes %v, want %s", c.level, got, c.want)
+		}
+	}
+	if !Elevel(99, Lerr) {
+		t.Error("unknown event type is filtered at Lerr, want it treated as an error")
+	}
+}
+
+func TestLevelOf(t *testing.T) {
+	vl := &Vlg{Level: Lerr}
+	vl.SetLevel("main.work", Ldbg)
+	vl.SetLevel("main.(*Srv)", Lwrn)
+	vl.SetLevel("github.com/user/app", Linf)
+	vl.SetLevel("github.com/user/app/db", Loff)
+
+	for _, c := range []struct {
+		funame string
+		want   int
+	}{
+		{"main.work", Ldbg},
+		{"main.work.func1", Ldbg},                 // замыкание
+		{"main.worker", Lerr},                     // не по границе имени
+		{"main.(*Srv).Run", Lwrn},                 // метод
+		{"main.(*Srver).Run", Lerr},               // другой тип
+		{"github.com/user/app.Run", Linf},         // функция пакета
+		{"github.com/user/app/api.Serve", Linf},   // вложенный пакет
+		{"github.com/user/app/db.Query", Loff},    // самое длинное совпадение
+		{"github.com/user/application.Run", Lerr}, // другой пакет с тем же началом
+		{"github.com/user/app/dbx.Query", Linf},   // не по границе имени вложенного пакета
+	} {
+		if got := vl.LevelOf(c.funame); got != c.want {
+			t.Errorf("LevelOf(%s) = %d, want %d", c.funame, got, c.want)
+		}
+	}
+
+	vl.DelLevel("main.work")
+	if got := vl.LevelOf("main.work"); got != Lerr {
+		t.Errorf("LevelOf after DelLevel = %d, want %d", got, Lerr)
+	}
+}
+
+// testLogAll запись события каждого типа из отдельной функции, уровень которой задается SetLevel
+func testLogAll(vl *Vlg) {
+	for _, etype := range []int{Eftl, Eerr, Ewrn, Einf, Edbg} {
+		vl.Vlog(1, Etname(etype), etype)
+	}
+}
+
+func TestLevelFilter(t *testing.T) {
+	rt := NewVrt()
+	rt.Log.Level = Lwrn
+	rt.Log.File = filepath.Join(t.TempDir(), "app.log")
+	rt.Log.Format = "json"
+	rt.Log.SetLevel(testFuncName(testLogAll), Ldbg)
+	if err := rt.Start(Vcfg{Quiet: true}); err != nil {
+		t.Fatal(err)
+	}
+	testLogAll(rt.Log)
+	for _, etype := range []int{Eftl, Eerr, Ewrn, Einf, Edbg} {
+		rt.Log.Vlog(2, Etname(etype), etype)
+	}
+	if err := rt.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(rt.Log.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	vles, err := ReadLog(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[uint64][]string{}
+	for _, vle := range vles {
+		got[vle.Pid()] = append(got[vle.Pid()], vle.Estr())
+	}
+	// pid 1 - функция с переопределением Ldbg, pid 2 - остальной код с уровнем лога Lwrn
+	if s := strings.Join(got[1], " "); s != "Ftl Err Wrn Inf Dbg" {
+		t.Errorf("events at Ldbg override: %s", s)
+	}
+	if s := strings.Join(got[2], " "); s != "Ftl Err Wrn" {
+		t.Errorf("events at Lwrn: %s, want warnings kept and info dropped", s)
+	}
+}
+
+// testFuncName полное имя функции, как его видит LevelOf
+func testFuncName(fn interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+}
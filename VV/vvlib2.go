@@ -3,9 +3,12 @@ package vv // Package vv статические файлы -> программн
 import (
 	"bytes"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -37,18 +40,108 @@ type Options struct {
 
 	// VariableName is the name of the http.FileSystem variable in the generated code.
 	// If left empty, it defaults to "assets".
+	// The same filesystem is also available as an io/fs filesystem in the variable "{{.VariableName}}FS".
 	VariableName string
 
 	// VariableComment is the comment of the http.FileSystem variable in the generated code.
 	// If left empty, it defaults to "{{.VariableName}} statically implements the virtual filesystem provided to vfsgen.".
 	VariableComment string
+
+	// Include is a list of glob patterns of files to embed. If empty, all files are embedded.
+	// A pattern containing "/" is matched against the file path relative to the input root
+	// (for example "img/*.png"), any other pattern against the file name (for example "*.css").
+	// A leading "/" is allowed and anchors a top-level name to the root (for example "/index.html").
+	// Patterns use the path.Match syntax.
+	// Directories left without embedded entries by Include and Exclude are not embedded;
+	// directories that are empty in the input filesystem are.
+	Include []string
+
+	// Exclude is a list of glob patterns of files and directories to skip, matched like Include.
+	// Excluding a directory skips everything in it. Exclude takes precedence over Include.
+	// DefaultExclude is applied as well, unless NoDefaultExclude is set.
+	Exclude []string
+
+	// NoDefaultExclude disables DefaultExclude.
+	NoDefaultExclude bool
+
+	// Compress is a list of per-pattern compression rules, matched like Include; the first matching rule applies.
+	// Files that match no rule use CompressAuto with gzip.BestCompression.
+	Compress []CompressRule
+
+	// RawExtensions lists extensions of already compressed files (".png"), which CompressAuto stores raw.
+	// If nil, it defaults to DefaultRawExtensions; use an empty non-nil slice to compress them too.
+	RawExtensions []string
+
+	// MinCompressSize and MaxCompressSize are the file size bounds in bytes for CompressAuto:
+	// smaller and larger files are stored raw. Zero means no bound.
+	MinCompressSize int64
+	MaxCompressSize int64
+
+	// ModTime, if not zero, is used as the modification time of all files and directories
+	// instead of their own, so that the output doesn't depend on when the input was checked out.
+	ModTime time.Time
+
+	// ZeroModTime sets the modification times of all files and directories to the zero time.
+	// Then the output depends only on the content of the input, and http.ServeContent sends no Last-Modified.
+	ZeroModTime bool
+}
+
+// CompressRule -----------------------------------------------------------------------------
+// CompressRule sets how files matching Pattern are stored in the generated code.
+type CompressRule struct {
+	Pattern string       // Glob pattern, matched like Options.Include.
+	Mode    CompressMode // Compression mode.
+	Level   int          // gzip compression level from 1 to 9. If 0, it defaults to gzip.BestCompression.
+}
+
+// CompressMode -----------------------------------------------------------------------------
+// CompressMode is a compression mode of CompressRule.
+type CompressMode int
+
+// Compression modes.
+const (
+	// CompressAuto stores the file gzip compressed, unless it has one of Options.RawExtensions,
+	// its size is out of the Options.MinCompressSize/MaxCompressSize bounds, or compression doesn't make it smaller.
+	CompressAuto CompressMode = iota
+	// CompressNever always stores the file raw.
+	CompressNever
+	// CompressAlways always stores the file gzip compressed, even if that doesn't make it smaller.
+	CompressAlways
+)
+
+// String -----------------------------------------------------------------------------
+func (m CompressMode) String() string {
+	switch m {
+	case CompressAuto:
+		return "auto"
+	case CompressNever:
+		return "never"
+	case CompressAlways:
+		return "always"
+	}
+	return "CompressMode(" + strconv.Itoa(int(m)) + ")"
+}
+
+// DefaultExclude -----------------------------------------------------------------------------
+// DefaultExclude is a list of patterns that Generate excludes unless Options.NoDefaultExclude is set:
+// dotfiles and dot directories (.git, .DS_Store) and editor backup and swap files.
+var DefaultExclude = []string{".*", "*~", "#*#", "*.swp", "*.swo", "*.bak"}
+
+// DefaultRawExtensions -----------------------------------------------------------------------------
+// DefaultRawExtensions is a list of extensions of already compressed files, which are not worth gzip compressing.
+var DefaultRawExtensions = []string{
+	".png", ".jpg", ".jpeg", ".gif", ".webp", ".avif", ".heic",
+	".woff", ".woff2",
+	".gz", ".tgz", ".bz2", ".xz", ".zst", ".br", ".zip", ".7z", ".rar", ".jar",
+	".mp3", ".mp4", ".m4a", ".m4v", ".aac", ".ogg", ".oga", ".ogv", ".opus", ".flac", ".webm", ".mov",
 }
 
 //-----------------------------------------------------------------------------
 type toc struct {
 	dirs              []*dirInfo
-	HasCompressedFile bool // There's at least one compressedFile.
-	HasFile           bool // There's at least one uncompressed file.
+	VariableName      string // Name of the http.FileSystem variable; the io/fs variable is named after it.
+	HasCompressedFile bool   // There's at least one compressedFile.
+	HasFile           bool   // There's at least one uncompressed file.
 }
 
 //-----------------------------------------------------------------------------
@@ -58,6 +151,8 @@ type fileInfo struct {
 	Name             string
 	ModTime          time.Time
 	UncompressedSize int64
+	Sha256           [32]byte // SHA-256 of the uncompressed content, computed while the content is written.
+	Fingerprint      string   // Hex form of Sha256.
 }
 
 //-----------------------------------------------------------------------------
@@ -91,42 +186,77 @@ type commentWriter struct {
 //-----------------------------------------------------------------------------
 // Generate Go code that statically implements input filesystem,
 // write the output to a file specified in opt.
+// The file is not rewritten if it already has the same content, so its modification time is kept.
 func Generate(input http.FileSystem, opt Options) error {
 	opt.fillMissing()
 
+	b, err := GenerateBytes(input, opt)
+	if err != nil {
+		return err
+	}
+
+	if old, err := ioutil.ReadFile(opt.Filename); err == nil && bytes.Equal(old, b) {
+		return nil // Up to date.
+	}
+
+	// Write output file (all at once).
+	err = ioutil.WriteFile(opt.Filename, b, 0644)
+	return err
+}
+
+// GenerateBytes -----------------------------------------------------------------------------
+// GenerateBytes is like Generate, but returns the generated Go code instead of writing it to opt.Filename.
+func GenerateBytes(input http.FileSystem, opt Options) ([]byte, error) {
+	opt.fillMissing()
+
 	// Use an in-memory buffer to generate the entire output.
 	buf := new(bytes.Buffer)
 	err := t.ExecuteTemplate(buf, "Header", opt)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var toc toc
-	err = findAndWriteFiles(buf, input, &toc)
+	toc := toc{VariableName: opt.VariableName}
+	err = findAndWriteFiles(buf, input, &opt, &toc)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	err = t.ExecuteTemplate(buf, "DirEntries", toc.dirs)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	err = t.ExecuteTemplate(buf, "Trailer", toc)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return buf.Bytes(), nil
+}
 
-	// Write output file (all at once).
-	err = ioutil.WriteFile(opt.Filename, buf.Bytes(), 0644)
-	return err
+// GenerateFS -----------------------------------------------------------------------------
+// GenerateFS is like Generate, but takes an io/fs filesystem as input, such as os.DirFS or embed.FS.
+// Its files must implement io.Seeker, as files of os.DirFS, embed.FS and fstest.MapFS do.
+func GenerateFS(input fs.FS, opt Options) error {
+	return Generate(http.FS(input), opt)
+}
+
+// GenerateBytesFS -----------------------------------------------------------------------------
+// GenerateBytesFS is like GenerateBytes, but takes an io/fs filesystem as input (see GenerateFS).
+func GenerateBytesFS(input fs.FS, opt Options) ([]byte, error) {
+	return GenerateBytes(http.FS(input), opt)
 }
 
 //-----------------------------------------------------------------------------
 // findAndWriteFiles recursively finds all the file paths in the given directory tree.
 // They are added to the given map as keys. Values will be safe function names
 // for each file, which will be used when generating the output code.
-func findAndWriteFiles(buf *bytes.Buffer, fs http.FileSystem, toc *toc) error {
+// Files and directories are filtered and compressed according to opt.
+func findAndWriteFiles(buf *bytes.Buffer, fs http.FileSystem, opt *Options, toc *toc) error {
+	dirs, err := keptDirs(fs, opt)
+	if err != nil {
+		return err
+	}
 
 	walkFn := func(path string, fi os.FileInfo, r io.ReadSeeker, err error) error {
 
@@ -134,25 +264,36 @@ func findAndWriteFiles(buf *bytes.Buffer, fs http.FileSystem, toc *toc) error {
 			return err
 		} // Consider all errors reading the input filesystem as fatal.
 
+		if !opt.Keep(path, fi.IsDir()) || fi.IsDir() && !dirs[path] {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		switch fi.IsDir() {
 		case false:
 			file := &fileInfo{
 				Path:             path,
 				Name:             pathpkg.Base(path),
-				ModTime:          fi.ModTime().UTC(),
+				ModTime:          opt.modTime(fi),
 				UncompressedSize: fi.Size(),
 			}
 
 			marker := buf.Len()
 
-			// Write CompressedFileInfo.
-			err = writeCompressedFileInfo(buf, file, r)
+			// Write CompressedFileInfo, unless the file is to be stored raw.
+			mode, level := opt.Compression(path, file.UncompressedSize)
+			err = errCompressedNotSmaller
+			if mode != CompressNever {
+				err = writeCompressedFileInfo(buf, file, r, level, mode == CompressAlways)
+			}
 			switch err {
 			default:
 				return err
 			case nil:
 				toc.HasCompressedFile = true
-			// If compressed file is not smaller than original, revert and write original file.
+			// If compressed file is not smaller than original, or it's stored raw, revert and write original file.
 			case errCompressedNotSmaller:
 				_, err = r.Seek(0, io.SeekStart)
 				if err != nil {
@@ -169,7 +310,7 @@ func findAndWriteFiles(buf *bytes.Buffer, fs http.FileSystem, toc *toc) error {
 				toc.HasFile = true
 			}
 		case true:
-			entries, err := readDirPaths(fs, path)
+			entries, err := readDirPaths(fs, path, opt, dirs)
 			if err != nil {
 				return err
 			}
@@ -177,7 +318,7 @@ func findAndWriteFiles(buf *bytes.Buffer, fs http.FileSystem, toc *toc) error {
 			dir := &dirInfo{
 				Path:    path,
 				Name:    pathpkg.Base(path),
-				ModTime: fi.ModTime().UTC(),
+				ModTime: opt.modTime(fi),
 				Entries: entries,
 			}
 
@@ -193,51 +334,96 @@ func findAndWriteFiles(buf *bytes.Buffer, fs http.FileSystem, toc *toc) error {
 		return nil
 	}
 
-	err := WalkFiles(fs, "/", walkFn)
+	err = WalkFiles(fs, "/", walkFn)
 	return err
 }
 
+//-----------------------------------------------------------------------------
+// keptDirs returns the set of directories that Generate embeds: the root, directories
+// with embedded entries and directories that are empty in the input filesystem.
+// Directories left empty only by Include, Exclude and DefaultExclude are pruned.
+func keptDirs(fs http.FileSystem, opt *Options) (map[string]bool, error) {
+	dirs := make(map[string]bool)
+	var visit func(dir string) (bool, error)
+	visit = func(dir string) (bool, error) {
+		fis, err := ReadDir(fs, dir)
+		if err != nil {
+			return false, err
+		}
+		keep := len(fis) == 0
+		for _, fi := range fis {
+			path := pathpkg.Join(dir, fi.Name())
+			switch {
+			case !opt.Keep(path, fi.IsDir()):
+			case !fi.IsDir():
+				keep = true
+			default:
+				sub, err := visit(path)
+				if err != nil {
+					return false, err
+				}
+				keep = keep || sub
+			}
+		}
+		dirs[dir] = keep
+		return keep, nil
+	}
+
+	if _, err := visit("/"); err != nil {
+		return nil, err
+	}
+	dirs["/"] = true
+	return dirs, nil
+}
+
 //-----------------------------------------------------------------------------
 // readDirPaths reads the directory named by dirname and returns
-// a sorted list of directory paths.
-func readDirPaths(fs http.FileSystem, dirname string) ([]string, error) {
+// a sorted list of paths of its entries that opt keeps, without pruned directories (see keptDirs).
+func readDirPaths(fs http.FileSystem, dirname string, opt *Options, dirs map[string]bool) ([]string, error) {
 	fis, err := ReadDir(fs, dirname)
 	if err != nil {
 		return nil, err
 	}
 
-	paths := make([]string, len(fis))
+	paths := make([]string, 0, len(fis))
 	for i := range fis {
-		paths[i] = pathpkg.Join(dirname, fis[i].Name())
+		path := pathpkg.Join(dirname, fis[i].Name())
+		if opt.Keep(path, fis[i].IsDir()) && (!fis[i].IsDir() || dirs[path]) {
+			paths = append(paths, path)
+		}
 	}
 	sort.Strings(paths)
 	return paths, nil
 }
 
 //-----------------------------------------------------------------------------
-// writeCompressedFileInfo writes CompressedFileInfo.
-// It returns errCompressedNotSmaller if compressed file is not smaller than original.
-func writeCompressedFileInfo(w io.Writer, file *fileInfo, r io.Reader) error {
+// writeCompressedFileInfo writes CompressedFileInfo, gzip compressed with the given level.
+// It returns errCompressedNotSmaller if compressed file is not smaller than original, unless always is set.
+func writeCompressedFileInfo(w io.Writer, file *fileInfo, r io.Reader, level int, always bool) error {
 	err := t.ExecuteTemplate(w, "CompressedFileInfo-Before", file)
 	if err != nil {
 		return err
 	}
 
 	sw := &stringWriter{Writer: w}
-	gw, _ := gzip.NewWriterLevel(sw, gzip.BestCompression)
-	_, err = io.Copy(gw, r)
+	gw, err := gzip.NewWriterLevel(sw, level)
 	if err != nil {
 		return err
 	}
+	hs := sha256.New()
+	_, err = io.Copy(gw, io.TeeReader(r, hs))
+	if err != nil {
+		return err
+	}
+	hs.Sum(file.Sha256[:0])
+	file.Fingerprint = hex.EncodeToString(file.Sha256[:])
 
 	err = gw.Close()
 	if err != nil {
 		return err
 	}
 
-	//if file.UncompressedSize > 32700 {sw.N = file.UncompressedSize + 1} // длинные файлы не зипуем
-
-	if sw.N >= file.UncompressedSize {
+	if sw.N >= file.UncompressedSize && !always {
 		return errCompressedNotSmaller
 	}
 
@@ -258,10 +444,13 @@ func writeFileInfo(w io.Writer, file *fileInfo, r io.Reader) error {
 	}
 
 	sw := &stringWriter{Writer: w}
-	_, err = io.Copy(sw, r)
+	hs := sha256.New()
+	_, err = io.Copy(sw, io.TeeReader(r, hs))
 	if err != nil {
 		return err
 	}
+	hs.Sum(file.Sha256[:0])
+	file.Fingerprint = hex.EncodeToString(file.Sha256[:])
 
 	err = t.ExecuteTemplate(w, "FileInfo-After", file)
 	return err
@@ -269,6 +458,18 @@ func writeFileInfo(w io.Writer, file *fileInfo, r io.Reader) error {
 
 var t = template.Must(template.New("").Funcs(template.FuncMap{
 	"quote": strconv.Quote,
+	"bytes32": func(b [32]byte) string {
+		var sb strings.Builder
+		sb.WriteString("[32]byte{")
+		for i, c := range b {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(&sb, "0x%02x", c)
+		}
+		sb.WriteString("}")
+		return sb.String()
+	},
 	"comment": func(s string) (string, error) {
 		var buf bytes.Buffer
 		cw := &commentWriter{W: &buf}
@@ -291,6 +492,7 @@ import (
 	"compress/gzip"
 	"fmt"
 	"io"
+	fspkg "io/fs"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -311,15 +513,19 @@ var {{.VariableName}} = func() http.FileSystem {
 			uncompressedSize: {{.UncompressedSize}},
 {{/* This blank line separating compressedContent is neccessary to prevent potential gofmt issues. See issue #19. */}}
 			compressedContent: []byte("{{end}}{{define "CompressedFileInfo-After"}}"),
+			fingerprint:       {{quote .Fingerprint}},
+			sha256:            {{bytes32 .Sha256}},
 		},
 {{end}}
 
 
 
 {{define "FileInfo-Before"}}		{{quote .Path}}: &vfsgen۰FileInfo{
-			name:    {{quote .Name}},
-			modTime: {{template "Time" .ModTime}},
-			content: []byte("{{end}}{{define "FileInfo-After"}}"),
+			name:        {{quote .Name}},
+			modTime:     {{template "Time" .ModTime}},
+			content:     []byte("{{end}}{{define "FileInfo-After"}}"),
+			fingerprint: {{quote .Fingerprint}},
+			sha256:      {{bytes32 .Sha256}},
 		},
 {{end}}
 
@@ -386,6 +592,8 @@ type vfsgen۰CompressedFileInfo struct {
 	modTime           time.Time
 	compressedContent []byte
 	uncompressedSize  int64
+	fingerprint       string
+	sha256            [32]byte
 }
 
 func (f *vfsgen۰CompressedFileInfo) Readdir(count int) ([]os.FileInfo, error) {
@@ -397,6 +605,12 @@ func (f *vfsgen۰CompressedFileInfo) GzipBytes() []byte {
 	return f.compressedContent
 }
 
+// Fingerprint returns the hex SHA-256 of the uncompressed content, computed at generation time.
+func (f *vfsgen۰CompressedFileInfo) Fingerprint() string { return f.fingerprint }
+
+// Sha256 returns the SHA-256 of the uncompressed content, computed at generation time.
+func (f *vfsgen۰CompressedFileInfo) Sha256() [32]byte { return f.sha256 }
+
 func (f *vfsgen۰CompressedFileInfo) Name() string       { return f.name }
 func (f *vfsgen۰CompressedFileInfo) Size() int64        { return f.uncompressedSize }
 func (f *vfsgen۰CompressedFileInfo) Mode() os.FileMode  { return 0444 }
@@ -413,23 +627,46 @@ type vfsgen۰CompressedFile struct {
 }
 
 func (f *vfsgen۰CompressedFile) Read(p []byte) (n int, err error) {
-
-	bf, err := ioutil.ReadAll(f.gr)
-  var i int64
-  for i=0; i < f.uncompressedSize; i++ {p[i] = bf[i]}
-	return int(i), err
+	if f.grPos > f.seekPos {
+		// Rewind to beginning.
+		err = f.gr.Reset(bytes.NewReader(f.compressedContent))
+		if err != nil {
+			return 0, err
+		}
+		f.grPos = 0
+	}
+	if f.grPos < f.seekPos {
+		// Fast-forward.
+		_, err = io.CopyN(ioutil.Discard, f.gr, f.seekPos-f.grPos)
+		if err == io.EOF {
+			// Seeking past the end is allowed; reads there return EOF.
+			f.grPos = f.seekPos
+			return 0, io.EOF
+		}
+		if err != nil {
+			return 0, err
+		}
+		f.grPos = f.seekPos
+	}
+	n, err = f.gr.Read(p)
+	f.grPos += int64(n)
+	f.seekPos = f.grPos
+	return n, err
 }
 func (f *vfsgen۰CompressedFile) Seek(offset int64, whence int) (int64, error) {
 	switch whence {
 	case io.SeekStart:
-		f.seekPos = 0 + offset
 	case io.SeekCurrent:
-		f.seekPos += offset
+		offset += f.seekPos
 	case io.SeekEnd:
-		f.seekPos = f.uncompressedSize + offset
+		offset += f.uncompressedSize
 	default:
-		panic(fmt.Errorf("invalid whence value: %v", whence))
+		return f.seekPos, fmt.Errorf("invalid whence value: %v", whence)
+	}
+	if offset < 0 {
+		return f.seekPos, fmt.Errorf("negative position: %v", offset)
 	}
+	f.seekPos = offset
 	return f.seekPos, nil
 }
 func (f *vfsgen۰CompressedFile) Close() error {
@@ -442,9 +679,11 @@ var _ = ioutil.Discard
 {{end}}{{if .HasFile}}
 // vfsgen۰FileInfo is a static definition of an uncompressed file (because it's not worth gzip compressing).
 type vfsgen۰FileInfo struct {
-	name    string
-	modTime time.Time
-	content []byte
+	name        string
+	modTime     time.Time
+	content     []byte
+	fingerprint string
+	sha256      [32]byte
 }
 
 func (f *vfsgen۰FileInfo) Readdir(count int) ([]os.FileInfo, error) {
@@ -454,6 +693,12 @@ func (f *vfsgen۰FileInfo) Stat() (os.FileInfo, error) { return f, nil }
 
 func (f *vfsgen۰FileInfo) NotWorthGzipCompressing() {}
 
+// Fingerprint returns the hex SHA-256 of the content, computed at generation time.
+func (f *vfsgen۰FileInfo) Fingerprint() string { return f.fingerprint }
+
+// Sha256 returns the SHA-256 of the content, computed at generation time.
+func (f *vfsgen۰FileInfo) Sha256() [32]byte { return f.sha256 }
+
 func (f *vfsgen۰FileInfo) Name() string       { return f.name }
 func (f *vfsgen۰FileInfo) Size() int64        { return int64(len(f.content)) }
 func (f *vfsgen۰FileInfo) Mode() os.FileMode  { return 0444 }
@@ -519,6 +764,88 @@ func (d *vfsgen۰Dir) Readdir(count int) ([]os.FileInfo, error) {
 	d.pos += count
 	return e, nil
 }
+
+func (d *vfsgen۰Dir) ReadDir(count int) ([]fspkg.DirEntry, error) {
+	fis, err := d.Readdir(count)
+	return vfsgen۰DirEntries(fis), err
+}
+
+// {{.VariableName}}FS is {{.VariableName}} as an io/fs filesystem,
+// for use with html/template.ParseFS, http.FS, fs.WalkDir and the like.
+var {{.VariableName}}FS = vfsgen۰IOFS({{.VariableName}}.(vfsgen۰FS))
+
+// vfsgen۰IOFS is vfsgen۰FS as an io/fs filesystem.
+// It implements fs.FS, fs.ReadDirFS, fs.StatFS and fs.ReadFileFS.
+type vfsgen۰IOFS vfsgen۰FS
+
+func (fsys vfsgen۰IOFS) Open(name string) (fspkg.File, error) {
+	if _, err := fsys.lookup("open", name); err != nil {
+		return nil, err
+	}
+	return vfsgen۰FS(fsys).Open(name)
+}
+
+func (fsys vfsgen۰IOFS) Stat(name string) (fspkg.FileInfo, error) {
+	f, err := fsys.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return f.(os.FileInfo), nil
+}
+
+func (fsys vfsgen۰IOFS) ReadDir(name string) ([]fspkg.DirEntry, error) {
+	f, err := fsys.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := f.(*vfsgen۰DirInfo)
+	if !ok {
+		return nil, &fspkg.PathError{Op: "readdir", Path: name, Err: fmt.Errorf("not a directory")}
+	}
+	return vfsgen۰DirEntries(d.entries), nil
+}
+
+func (fsys vfsgen۰IOFS) ReadFile(name string) ([]byte, error) {
+	f, err := fsys.lookup("read", name)
+	if err != nil {
+		return nil, err
+	}
+	switch f := f.(type) {{"{"}}{{if .HasCompressedFile}}
+	case *vfsgen۰CompressedFileInfo:
+		gr, err := gzip.NewReader(bytes.NewReader(f.compressedContent))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(gr){{end}}{{if .HasFile}}
+	case *vfsgen۰FileInfo:
+		return append([]byte(nil), f.content...), nil{{end}}
+	case *vfsgen۰DirInfo:
+		return nil, &fspkg.PathError{Op: "read", Path: name, Err: fmt.Errorf("is a directory")}
+	default:
+		// This should never happen because we generate only the above types.
+		panic(fmt.Sprintf("unexpected type %T", f))
+	}
+}
+
+// lookup returns the static definition of the file or directory with io/fs path name.
+func (fsys vfsgen۰IOFS) lookup(op, name string) (interface{}, error) {
+	if !fspkg.ValidPath(name) {
+		return nil, &fspkg.PathError{Op: op, Path: name, Err: fspkg.ErrInvalid}
+	}
+	f, ok := fsys[pathpkg.Join("/", name)]
+	if !ok {
+		return nil, &fspkg.PathError{Op: op, Path: name, Err: fspkg.ErrNotExist}
+	}
+	return f, nil
+}
+
+func vfsgen۰DirEntries(fis []os.FileInfo) []fspkg.DirEntry {
+	entries := make([]fspkg.DirEntry, len(fis))
+	for i, fi := range fis {
+		entries[i] = fspkg.FileInfoToDirEntry(fi)
+	}
+	return entries
+}
 {{end}}
 
 
@@ -532,6 +859,105 @@ func (d *vfsgen۰Dir) Readdir(count int) ([]os.FileInfo, error) {
 {{end}}
 `))
 
+// Keep -----------------------------------------------------------------------------
+// Keep reports whether Generate embeds the file or directory with the given path,
+// according to Include, Exclude and DefaultExclude. The path is rooted at the input root, like "/img/logo.png".
+func (opt *Options) Keep(path string, isDir bool) bool {
+	path = strings.TrimPrefix(path, "/")
+	if path == "" {
+		return true // The root is always embedded.
+	}
+	for _, p := range opt.Exclude {
+		if globMatch(p, path) {
+			return false
+		}
+	}
+	if !opt.NoDefaultExclude {
+		for _, p := range DefaultExclude {
+			if globMatch(p, path) {
+				return false
+			}
+		}
+	}
+	if isDir || len(opt.Include) == 0 {
+		return true
+	}
+	for _, p := range opt.Include {
+		if globMatch(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Compression -----------------------------------------------------------------------------
+// Compression returns how Generate stores the file with the given path and size:
+// CompressNever (raw), CompressAlways, or CompressAuto (compressed if that makes it smaller), and the gzip level.
+// The path is rooted at the input root, like "/img/logo.png".
+func (opt *Options) Compression(path string, size int64) (CompressMode, int) {
+	path = strings.TrimPrefix(path, "/")
+	for _, r := range opt.Compress {
+		if globMatch(r.Pattern, path) {
+			level := r.Level
+			if level == 0 {
+				level = gzip.BestCompression
+			}
+			if r.Mode == CompressAuto && !opt.worthCompressing(path, size) {
+				return CompressNever, level
+			}
+			return r.Mode, level
+		}
+	}
+	if !opt.worthCompressing(path, size) {
+		return CompressNever, gzip.BestCompression
+	}
+	return CompressAuto, gzip.BestCompression
+}
+
+//-----------------------------------------------------------------------------
+// worthCompressing reports whether CompressAuto should try to compress the file:
+// it is not already compressed and its size is within the MinCompressSize/MaxCompressSize bounds.
+func (opt *Options) worthCompressing(path string, size int64) bool {
+	if size < opt.MinCompressSize || opt.MaxCompressSize > 0 && size > opt.MaxCompressSize {
+		return false
+	}
+	exts := opt.RawExtensions
+	if exts == nil {
+		exts = DefaultRawExtensions
+	}
+	ext := strings.ToLower(pathpkg.Ext(path))
+	for _, e := range exts {
+		if strings.ToLower(e) == ext {
+			return false
+		}
+	}
+	return true
+}
+
+//-----------------------------------------------------------------------------
+// modTime returns the modification time of fi to embed: ModTime or zero if set, otherwise the time of fi in UTC.
+func (opt *Options) modTime(fi os.FileInfo) time.Time {
+	switch {
+	case opt.ZeroModTime:
+		return time.Time{}
+	case !opt.ModTime.IsZero():
+		return opt.ModTime.UTC()
+	}
+	return fi.ModTime().UTC()
+}
+
+//-----------------------------------------------------------------------------
+// globMatch matches a pattern containing "/" against the path, any other pattern against its last element.
+// The path is relative to the input root, so a leading "/" of the pattern is dropped.
+func globMatch(pattern string, path string) bool {
+	if !strings.Contains(pattern, "/") {
+		path = pathpkg.Base(path)
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+	ok, _ := pathpkg.Match(pattern, path)
+	return ok
+}
+
 //-----------------------------------------------------------------------------
 // fillMissing sets default values for mandatory options that are left empty.
 func (opt *Options) fillMissing() {
@@ -693,6 +1119,21 @@ func WalkFiles(fs http.FileSystem, root string, walkFn WalkFilesFunc) error {
 	return walkFiles(fs, root, info, file, walkFn)
 }
 
+// WalkFilesFS -----------------------------------------------------------------------------
+// WalkFilesFS is like WalkFiles, but walks an io/fs filesystem. Paths are io/fs paths:
+// unrooted, slash-separated, with "." for the root of fsys.
+func WalkFilesFS(fsys fs.FS, root string, walkFn WalkFilesFunc) error {
+	if !fs.ValidPath(root) {
+		return walkFn(root, nil, nil, &fs.PathError{Op: "walk", Path: root, Err: fs.ErrInvalid})
+	}
+	return WalkFiles(http.FS(fsys), pathpkg.Join("/", root), func(path string, info os.FileInfo, rs io.ReadSeeker, err error) error {
+		if path = strings.TrimPrefix(path, "/"); path == "" {
+			path = "."
+		}
+		return walkFn(path, info, rs, err)
+	})
+}
+
 //-----------------------------------------------------------------------------
 // walkFiles recursively descends path, calling walkFn.
 // It closes the input file after it's done with it, so the caller shouldn't.
@@ -786,4 +1227,31 @@ func ReadFile(fs http.FileSystem, path string) ([]byte, error) {
 	return ioutil.ReadAll(rc)
 }
 
+// ReadDirFS -----------------------------------------------------------------------------
+// ReadDirFS is like ReadDir, but reads a directory of an io/fs filesystem. name is an io/fs path.
+func ReadDirFS(fsys fs.FS, name string) ([]os.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	return ReadDir(http.FS(fsys), pathpkg.Join("/", name))
+}
+
+// StatFS -----------------------------------------------------------------------------
+// StatFS is like Stat, but describes a file of an io/fs filesystem. name is an io/fs path.
+func StatFS(fsys fs.FS, name string) (os.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	return Stat(http.FS(fsys), pathpkg.Join("/", name))
+}
+
+// ReadFileFS -----------------------------------------------------------------------------
+// ReadFileFS is like ReadFile, but reads a file of an io/fs filesystem. name is an io/fs path.
+func ReadFileFS(fsys fs.FS, name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+	return ReadFile(http.FS(fsys), pathpkg.Join("/", name))
+}
+
 //-----------------------------------------------------------------------------
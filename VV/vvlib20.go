@@ -0,0 +1,220 @@
+package vv
+
+// Обработчик статических файлов:
+// - готовый http.Handler поверх любой http.FileSystem (http.Dir, сгенерированной vfsgen и т.п.)
+//   вместо своей маршрутизации вокруг StatToHttp в каждом приложении
+// - тип содержимого определяется по расширению, а если по нему не понять - по началу содержимого
+// - для каталога отдается его Index (index.html); путь каталога без "/" на конце перенаправляется на путь с "/"
+// - SPA: неизвестный путь без расширения (маршрут одностраничного приложения) получает файл SPA с кодом 200
+// - NotFound: своя страница 404 из той же файловой системы
+// - пути с "..", "\" и нулевым байтом отвергаются (400) до обращения к файловой системе
+// - каждый запрос пишется в Vlogger одним событием: метод, путь, код ответа, байты, длительность
+//   (отдача файла отдельно не пишется, ошибки - пишутся)
+// - файлы отдаются через StatToHttpReq: условные запросы, Range и сжатые файлы vfsgen (см. vvlib18.go)
+// - адреса с отпечатками содержимого и политики Cache-Control (Cache) - см. vvlib21.go
+//
+// Пример:
+//   http.Handle("/", vv.PidHandler(vv.StatHandler(assets)))
+//   http.Handle("/app/", vv.PidHandler(&vv.Vstat{Assets: assets, Prefix: "/app", SPA: "/index.html", NotFound: "/404.html"}))
+
+import (
+	"errors"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Vstat обработчик статических файлов
+type Vstat struct {
+	Assets   http.FileSystem // файловая система
+	Prefix   string          // префикс пути url, который отрезается перед поиском файла ("" - нет)
+	Index    string          // файл каталога ("" - index.html)
+	SPA      string          // файл для неизвестных путей без расширения ("" - нет, ответ 404)
+	NotFound string          // страница для ответа 404 ("" - текст "404 page not found")
+	Cache    []Vccp          // политики Cache-Control по путям файлов (см. vvlib21.go)
+}
+
+// StatHandler -----------------------------------------------------------------------------
+// web server: обработчик статических файлов из assets с умолчаниями
+func StatHandler(assets http.FileSystem) *Vstat {
+	return &Vstat{Assets: assets}
+}
+
+// ServeHTTP -----------------------------------------------------------------------------
+func (sh *Vstat) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pid := GetpidCtx(r.Context()) // id обработки запроса
+	r = r.WithContext(CtxWithPid(r.Context(), pid))
+	cw := &vstatw{ResponseWriter: w}
+	start := time.Now()
+
+	sh.serve(cw, r, pid)
+
+	Vlogger.Vlog(pid, r.Method+" "+r.URL.Path+" "+strconv.Itoa(cw.code)+" "+strconv.Itoa(cw.n)+"B "+time.Since(start).String(), Einf)
+}
+
+// serve -----------------------------------------------------------------------------
+// выбор файла по пути запроса и его отдача
+func (sh *Vstat) serve(w *vstatw, r *http.Request, pid uint64) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	upath, ok := statStrip(r.URL.Path, sh.Prefix)
+	if !ok {
+		sh.notFound(w, r)
+		return
+	}
+
+	name, ok := statPath(upath)
+	if !ok {
+		Vlogger.Vlog(pid, "Static: bad path: "+r.URL.Path, Ewrn)
+		http.Error(w, "400 bad request", http.StatusBadRequest)
+		return
+	}
+
+	fstat, err := statStat(sh.Assets, name)
+	if err == nil && fstat.IsDir() {
+		if !strings.HasSuffix(upath, "/") { // каталог - только с "/" на конце, иначе ломаются относительные ссылки
+			statRedirect(w, r, r.URL.EscapedPath()+"/")
+			return
+		}
+		name = path.Join(name, Vifs(sh.Index == "", "index.html", sh.Index))
+		fstat, err = statStat(sh.Assets, name)
+		if err == nil && fstat.IsDir() {
+			err = fs.ErrNotExist
+		}
+	}
+
+	cache := "" // Cache-Control
+	if errors.Is(err, fs.ErrNotExist) {
+		if orig, fp, ok := statUnfingerprint(name); ok { // адрес с отпечатком - ищем исходный файл
+			if ofi, ferr := statStat(sh.Assets, orig); ferr == nil && !ofi.IsDir() {
+				cur, _ := Fingerprint(sh.Assets, orig)
+				name, err = orig, nil
+				cache = Vifs(strings.HasPrefix(cur, fp), CcImmutable, CcNoCache) // устаревший отпечаток - текущий файл без долгого кеша
+			}
+		}
+	}
+
+	switch {
+	case err == nil:
+	case !errors.Is(err, fs.ErrNotExist):
+		Vlogger.Vlog(pid, "Static: "+name+": "+err.Error(), Eerr)
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	case sh.SPA != "" && path.Ext(name) == "": // маршрут одностраничного приложения
+		name = sh.SPA
+	default:
+		sh.notFound(w, r)
+		return
+	}
+
+	if cache == "" {
+		cache = sh.cacheOf(name)
+	}
+	if cache != "" {
+		w.Header().Set("Cache-Control", cache)
+	}
+	if err = statToHttpReq(w, r, sh.Assets, name, "", false); err != nil && w.code == 0 { // запрос пишет в лог ServeHTTP
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+	}
+}
+
+// notFound -----------------------------------------------------------------------------
+// ответ 404: своя страница NotFound или текст
+func (sh *Vstat) notFound(w *vstatw, r *http.Request) {
+	if sh.NotFound != "" {
+		ftype := mime.TypeByExtension(path.Ext(sh.NotFound))
+		if ftype == "" {
+			ftype = "text/html; charset=utf-8"
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		err := statToHttp(r.Context(), &vcodew{ResponseWriter: w, code: http.StatusNotFound}, sh.Assets, sh.NotFound, ftype, false)
+		if err == nil || w.code != 0 {
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// statStrip -----------------------------------------------------------------------------
+// путь url без префикса prefix: false - путь не под префиксом ("/apple.png" не под "/app")
+func statStrip(upath string, prefix string) (string, bool) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return upath, true
+	}
+	p, ok := strings.CutPrefix(upath, prefix)
+	if !ok || p != "" && p[0] != '/' {
+		return "", false
+	}
+	return p, true
+}
+
+// statPath -----------------------------------------------------------------------------
+// имя файла по пути url: false - путь с "..", "\" или нулевым байтом
+func statPath(upath string) (string, bool) {
+	if strings.ContainsAny(upath, "\\\x00") {
+		return "", false
+	}
+	for _, seg := range strings.Split(upath, "/") {
+		if seg == ".." {
+			return "", false
+		}
+	}
+	return path.Clean("/" + upath), true
+}
+
+// statStat -----------------------------------------------------------------------------
+// свойства файла name
+func statStat(assets http.FileSystem, name string) (fs.FileInfo, error) {
+	f, err := assets.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+// statRedirect -----------------------------------------------------------------------------
+// перенаправление на путь target с сохранением параметров запроса
+func statRedirect(w http.ResponseWriter, r *http.Request, target string) {
+	if q := r.URL.RawQuery; q != "" {
+		target += "?" + q
+	}
+	w.Header().Set("Location", target)
+	w.WriteHeader(http.StatusMovedPermanently)
+}
+
+// vcodew http-ответ с заданным кодом вместо 200 (для страницы 404)
+type vcodew struct {
+	http.ResponseWriter
+	code  int  // код ответа
+	wrote bool // код уже отправлен
+}
+
+// WriteHeader -----------------------------------------------------------------------------
+func (cw *vcodew) WriteHeader(code int) {
+	if cw.wrote {
+		return
+	}
+	cw.wrote = true
+	if code == http.StatusOK {
+		code = cw.code
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+// Write -----------------------------------------------------------------------------
+func (cw *vcodew) Write(p []byte) (int, error) {
+	if !cw.wrote {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(p)
+}
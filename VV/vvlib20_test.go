@@ -0,0 +1,131 @@
+package vv
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testServe ответ обработчика h на запрос method target
+func testServe(h http.Handler, method string, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+func TestVstat(t *testing.T) {
+	assets := testFS(map[string]string{
+		"index.html":     "<html>index</html>",
+		"404.html":       "<html>not found</html>",
+		"docs/index.htm": "<html>docs</html>",
+		"img/logo":       "\x89PNG\r\n\x1a\n0000",
+		"empty/.keep":    "",
+		"app.js":         "console.log(1)",
+	}, nil)
+	sh := &Vstat{Assets: assets, Prefix: "/app/", Index: "index.htm", SPA: "/index.html", NotFound: "/404.html"}
+
+	for _, c := range []struct {
+		method string
+		target string
+		code   int
+		body   string // подстрока тела
+		hdr    string // "имя: значение" заголовка ответа
+	}{
+		{"GET", "/app/app.js", 200, "console.log(1)", "Content-Type: text/javascript; charset=utf-8"},
+		{"HEAD", "/app/app.js", 200, "", ""},
+		{"POST", "/app/app.js", 405, "", "Allow: GET, HEAD"},
+		{"GET", "/app/docs/", 200, "docs", ""},
+		{"GET", "/app/docs?x=1", 301, "", "Location: /app/docs/?x=1"},
+		{"GET", "/app/empty/", 404, "not found", "Cache-Control: no-cache"}, // каталог без Index: SPA только для путей без расширения
+		{"GET", "/app/img/logo", 200, "PNG", "Content-Type: image/png"},     // тип по содержимому
+		{"GET", "/app/users/42", 200, "index", ""},                          // SPA
+		{"GET", "/app/missing.css", 404, "not found", ""},
+		{"GET", "/app", 301, "", "Location: /app/"},
+		{"GET", "/apple.png", 404, "not found", ""}, // не под префиксом
+		{"GET", "/app/a/../app.js", 400, "", ""},
+		{"GET", "/app/a%5Cb", 400, "", ""},
+		{"GET", "/app/a%00b", 400, "", ""},
+	} {
+		w := testServe(sh, c.method, c.target)
+		if w.Code != c.code || !strings.Contains(w.Body.String(), c.body) {
+			t.Errorf("%s %s: %d %q, want %d with %q", c.method, c.target, w.Code, w.Body, c.code, c.body)
+		}
+		if name, value, ok := strings.Cut(c.hdr, ": "); ok && w.Header().Get(name) != value {
+			t.Errorf("%s %s: %s = %q, want %q", c.method, c.target, name, w.Header().Get(name), value)
+		}
+	}
+}
+
+func TestVstatLog(t *testing.T) {
+	stderr := testStderr(t) // Vlogger не запущен: события идут в стандартный поток ошибок
+	assets := testFS(map[string]string{"app.js": "console.log(1)", "404.html": "<p>not found</p>"}, nil)
+	sh := &Vstat{Assets: assets, NotFound: "/404.html"}
+	testServe(sh, "GET", "/app.js")
+	testServe(sh, "GET", "/missing.js")
+
+	b, err := os.ReadFile(stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := string(b)
+	for _, c := range []struct {
+		text string
+		n    int
+	}{
+		{"GET /app.js 200 14B", 1},
+		{"GET /missing.js 404 ", 1},
+		{"was sent to http-reply", 0}, // запрос пишет в лог сам Vstat, отдача файла отдельно не пишется
+	} {
+		if n := strings.Count(log, c.text); n != c.n {
+			t.Errorf("%q logged %d times, want %d:\n%s", c.text, n, c.n, log)
+		}
+	}
+
+	// StatToHttpReq вне Vstat по-прежнему пишет отданный файл
+	testReq(t, assets, "/app.js")
+	if b, _ = os.ReadFile(stderr); !strings.Contains(string(b), "/app.js was sent to http-reply (200)") {
+		t.Errorf("StatToHttpReq did not log the file:\n%s", b)
+	}
+}
+
+func TestVstatDefaults(t *testing.T) {
+	sh := StatHandler(testFS(map[string]string{"index.html": "<p>root</p>", "sub/index.html": "<p>sub</p>"}, nil))
+
+	for _, c := range []struct {
+		target string
+		code   int
+		body   string
+	}{
+		{"/", 200, "root"},
+		{"/sub/", 200, "sub"},
+		{"/sub/index.html", 200, "sub"},
+		{"/nope", 404, "404 page not found"},
+	} {
+		w := testServe(sh, "GET", c.target)
+		if w.Code != c.code || !strings.Contains(w.Body.String(), c.body) {
+			t.Errorf("%s: %d %q, want %d with %q", c.target, w.Code, w.Body, c.code, c.body)
+		}
+	}
+}
+
+func TestStatStrip(t *testing.T) {
+	for _, c := range []struct {
+		upath, prefix string
+		want          string
+		ok            bool
+	}{
+		{"/a.png", "", "/a.png", true},
+		{"/app", "/app", "", true},
+		{"/app/", "/app", "/", true},
+		{"/app/x.js", "/app/", "/x.js", true},
+		{"/apple.png", "/app", "", false},
+		{"/other", "/app", "", false},
+	} {
+		got, ok := statStrip(c.upath, c.prefix)
+		if got != c.want || ok != c.ok {
+			t.Errorf("statStrip(%q, %q) = %q, %v; want %q, %v", c.upath, c.prefix, got, ok, c.want, c.ok)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package vv
+
+// Отпечатки содержимого и политики кеширования статических файлов:
+// - отпечаток файла - sha256 содержимого в hex; Generate считает его при генерации и встраивает в код
+//   (метод Fingerprint сгенерированных файлов), для прочих файловых систем он считается при первом обращении
+// - адрес с отпечатком: app.js -> app.3f2a9c0d1e.js (первые 10 hex-символов отпечатка перед расширением)
+// - манифест (Vman) сопоставляет логические имена файлов адресам с отпечатками: для шаблонов (URL)
+//   и для клиентского кода (Handler отдает манифест в JSON)
+// - обработчик Vstat (см. vvlib20.go) отдает файл по адресу с отпечатком: совпавший отпечаток -
+//   Cache-Control: CcImmutable, устаревший (файл с тех пор изменился) - текущий файл с CcNoCache
+// - Vstat.Cache задает Cache-Control для прочих путей по шаблонам (первый подошедший)
+// - Integrity - значение атрибута integrity (Subresource Integrity) по тому же отпечатку
+//
+// Пример:
+//   man, _ := vv.NewManifest(assets, "/static")
+//   tpl := template.New("").Funcs(template.FuncMap{"asset": man.URL, "sri": man.Integrity})
+//   // <script src="{{asset "/app.js"}}" integrity="{{sri "/app.js"}}"> -> src="/static/app.3f2a9c0d1e.js" integrity="sha256-..."
+//   http.Handle("/static/", &vv.Vstat{Assets: assets, Prefix: "/static", Cache: []vv.Vccp{
+//       {Pattern: "*.html", Value: vv.CcNoCache},
+//       {Pattern: "/img/", Value: "public, max-age=86400"},
+//   }})
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// значения Cache-Control
+const (
+	CcImmutable = "public, max-age=31536000, immutable" // файл по адресу с отпечатком: не меняется никогда
+	CcNoCache   = "no-cache"                            // кешировать, но проверять при каждом обращении
+)
+
+// число hex-символов отпечатка в адресе файла
+const fpLen = 10
+
+// vfprinter файл с отпечатком, посчитанным при генерации (vfsgen۰FileInfo, vfsgen۰CompressedFileInfo)
+type vfprinter interface {
+	Fingerprint() string
+}
+
+// Vccp политика кеширования: Cache-Control для файлов, подходящих под шаблон
+// - Pattern с "/" в начале сравнивается с полным путем файла (path.Match), с "/" на конце - как префикс пути
+// - Pattern без "/" в начале сравнивается с именем файла без каталога, например "*.html"
+type Vccp struct {
+	Pattern string // шаблон пути или имени файла
+	Value   string // значение заголовка Cache-Control
+}
+
+// Vman манифест статических файлов: логические имена -> адреса с отпечатками
+type Vman struct {
+	Prefix string // префикс адресов (путь, на котором смонтирован обработчик файлов)
+
+	urls  map[string]string // логическое имя -> путь с отпечатком
+	names map[string]string // путь с отпечатком -> логическое имя
+	sris  map[string]string // логическое имя -> значение integrity
+}
+
+// Fingerprint -----------------------------------------------------------------------------
+// отпечаток (sha256 в hex) содержимого файла name
+func Fingerprint(assets http.FileSystem, name string) (string, error) {
+	file, fstat, rd, err := statOpen(0, assets, name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if fp := fileFingerprint(file); fp != "" {
+		return fp, nil
+	}
+	return statHash(assets, rd, name, fstat)
+}
+
+// fileFingerprint -----------------------------------------------------------------------------
+// отпечаток файла, посчитанный при генерации ("" - его нет или это не sha256 в hex)
+// - vfprinter может реализовать любой http.File, поэтому значение проверяется, а не берется на веру
+func fileFingerprint(file http.File) string {
+	fp, ok := file.(vfprinter)
+	if !ok {
+		return ""
+	}
+	sum := fp.Fingerprint()
+	if len(sum) != 2*sha256.Size {
+		return ""
+	}
+	if _, err := hex.DecodeString(sum); err != nil {
+		return ""
+	}
+	return sum
+}
+
+// Integrity -----------------------------------------------------------------------------
+// значение атрибута integrity (Subresource Integrity) для файла name: "sha256-" + sha256 содержимого в base64
+func Integrity(assets http.FileSystem, name string) (string, error) {
+	fp, err := Fingerprint(assets, name)
+	if err != nil {
+		return "", err
+	}
+	return fpIntegrity(fp)
+}
+
+// fpIntegrity -----------------------------------------------------------------------------
+// значение integrity по отпечатку fp
+func fpIntegrity(fp string) (string, error) {
+	sum, err := hex.DecodeString(fp)
+	if err != nil {
+		return "", err
+	}
+	return "sha256-" + base64.StdEncoding.EncodeToString(sum), nil
+}
+
+// FingerprintName -----------------------------------------------------------------------------
+// путь файла с отпечатком: "/js/app.js" -> "/js/app.3f2a9c0d1e.js"
+func FingerprintName(name string, fp string) string {
+	if len(fp) > fpLen {
+		fp = fp[:fpLen]
+	}
+	dir, base := path.Split(name)
+	ext := path.Ext(base)
+	return dir + strings.TrimSuffix(base, ext) + "." + fp + ext
+}
+
+// statUnfingerprint -----------------------------------------------------------------------------
+// разбор пути с отпечатком: "/js/app.3f2a9c0d1e.js" -> "/js/app.js", "3f2a9c0d1e"
+func statUnfingerprint(name string) (string, string, bool) {
+	dir, base := path.Split(name)
+	ext := path.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+
+	i := strings.LastIndexByte(stem, '.')
+	if i < 0 || len(stem)-i-1 != fpLen {
+		if ext == "" || len(ext) != fpLen+1 { // файл без расширения: "/LICENSE.3f2a9c0d1e"
+			return "", "", false
+		}
+		i, stem, ext = len(stem), base, ""
+	}
+	fp := stem[i+1:]
+	if _, err := hex.DecodeString(fp); err != nil || strings.ToLower(fp) != fp {
+		return "", "", false
+	}
+	return dir + stem[:i] + ext, fp, true
+}
+
+// NewManifest -----------------------------------------------------------------------------
+// манифест всех файлов assets с адресами под префиксом prefix
+func NewManifest(assets http.FileSystem, prefix string) (*Vman, error) {
+	m := &Vman{Prefix: strings.TrimSuffix(prefix, "/"), urls: make(map[string]string), names: make(map[string]string),
+		sris: make(map[string]string)}
+
+	err := Walk(assets, "/", func(name string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		fp, err := Fingerprint(assets, name)
+		if err != nil {
+			return err
+		}
+		sri, err := fpIntegrity(fp)
+		if err != nil {
+			return err
+		}
+		fname := FingerprintName(name, fp)
+		m.urls[name], m.names[fname], m.sris[name] = fname, name, sri
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// URL -----------------------------------------------------------------------------
+// адрес файла name с отпечатком; файла нет в манифесте - адрес без отпечатка
+func (m *Vman) URL(name string) string {
+	name = path.Clean("/" + name)
+	if fname, ok := m.urls[name]; ok {
+		return m.Prefix + fname
+	}
+	return m.Prefix + name
+}
+
+// Integrity -----------------------------------------------------------------------------
+// значение атрибута integrity файла name ("" - файла нет в манифесте)
+func (m *Vman) Integrity(name string) string {
+	return m.sris[path.Clean("/"+name)]
+}
+
+// Lookup -----------------------------------------------------------------------------
+// логическое имя файла по пути с отпечатком (без префикса)
+func (m *Vman) Lookup(fname string) (string, bool) {
+	name, ok := m.names[fname]
+	return name, ok
+}
+
+// Map -----------------------------------------------------------------------------
+// манифест: логическое имя -> адрес с отпечатком
+func (m *Vman) Map() map[string]string {
+	mp := make(map[string]string, len(m.urls))
+	for name, fname := range m.urls {
+		mp[name] = m.Prefix + fname
+	}
+	return mp
+}
+
+// Handler -----------------------------------------------------------------------------
+// web server: манифест в JSON для клиентского кода
+func (m *Vman) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", CcNoCache)
+		json.NewEncoder(w).Encode(m.Map())
+	})
+}
+
+// cacheOf -----------------------------------------------------------------------------
+// Cache-Control для файла name по политикам Cache ("" - не задавать)
+func (sh *Vstat) cacheOf(name string) string {
+	for _, p := range sh.Cache {
+		var ok bool
+		switch {
+		case strings.HasPrefix(p.Pattern, "/") && strings.HasSuffix(p.Pattern, "/"):
+			ok = strings.HasPrefix(name, p.Pattern)
+		case strings.HasPrefix(p.Pattern, "/"):
+			ok, _ = path.Match(p.Pattern, name)
+		default:
+			ok, _ = path.Match(p.Pattern, path.Base(name))
+		}
+		if ok {
+			return p.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,120 @@
+package vv
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFingerprintName(t *testing.T) {
+	const fp = "3f2a9c0d1e"
+	for _, c := range []struct {
+		name  string
+		fname string
+	}{
+		{"/js/app.js", "/js/app.3f2a9c0d1e.js"},
+		{"/app.min.js", "/app.min.3f2a9c0d1e.js"},
+		{"/LICENSE", "/LICENSE.3f2a9c0d1e"},
+	} {
+		if got := FingerprintName(c.name, fp+"ffff"); got != c.fname {
+			t.Errorf("FingerprintName(%q) = %q, want %q", c.name, got, c.fname)
+		}
+		name, gotfp, ok := statUnfingerprint(c.fname)
+		if !ok || name != c.name || gotfp != fp {
+			t.Errorf("statUnfingerprint(%q) = %q, %q, %v; want %q", c.fname, name, gotfp, ok, c.name)
+		}
+	}
+
+	for _, fname := range []string{"/app.js", "/app.3F2A9C0D1E.js", "/app.3f2a9c0d1x.js", "/app.3f2a9c.js"} {
+		if _, _, ok := statUnfingerprint(fname); ok {
+			t.Errorf("statUnfingerprint(%q): want no fingerprint", fname)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	const css = "body{}"
+	assets := testFS(map[string]string{"app.js": "alert(1)"}, map[string]string{"site.css": css, "bad.css": css})
+	assets.gz["/site.css"].fp = testSha256(css) // посчитан при генерации
+	assets.gz["/bad.css"].fp = "abc"            // не sha256: считается заново
+
+	for _, c := range []struct {
+		name string
+		want string
+	}{
+		{"/app.js", testSha256("alert(1)")},
+		{"/site.css", testSha256(css)},
+		{"/bad.css", testSha256(css)},
+	} {
+		fp, err := Fingerprint(assets, c.name)
+		if err != nil || fp != c.want {
+			t.Errorf("Fingerprint(%s) = %q, %v; want %q", c.name, fp, err, c.want)
+		}
+	}
+
+	sri, err := Integrity(assets, "/app.js")
+	if err != nil || sri != "sha256-bhHHL3z2vDgxUt0W3dWQOrprscmda2Y5pLsLg4GF+pI=" {
+		t.Errorf("Integrity = %q, %v", sri, err)
+	}
+	if _, err := Fingerprint(assets, "/none.js"); err == nil {
+		t.Error("Fingerprint of a missing file: want error")
+	}
+}
+
+func TestManifest(t *testing.T) {
+	assets := testFS(map[string]string{"js/app.js": "alert(1)", "index.html": "<p>"}, nil)
+	m, err := NewManifest(assets, "/static/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fp := testSha256("alert(1)")[:fpLen]
+	url := "/static/js/app." + fp + ".js"
+	if got := m.URL("js/app.js"); got != url {
+		t.Errorf("URL = %q, want %q", got, url)
+	}
+	if got := m.URL("/none.js"); got != "/static/none.js" {
+		t.Errorf("URL of a missing file = %q", got)
+	}
+	if name, ok := m.Lookup("/js/app." + fp + ".js"); !ok || name != "/js/app.js" {
+		t.Errorf("Lookup = %q, %v", name, ok)
+	}
+	if !strings.HasPrefix(m.Integrity("/js/app.js"), "sha256-") || m.Integrity("/none.js") != "" {
+		t.Errorf("Integrity = %q", m.Integrity("/js/app.js"))
+	}
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/manifest.json", nil))
+	var mp map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &mp); err != nil || mp["/js/app.js"] != url || len(mp) != 2 {
+		t.Errorf("manifest JSON = %s, %v", w.Body, err)
+	}
+}
+
+func TestVstatFingerprint(t *testing.T) {
+	assets := testFS(map[string]string{"app.js": "alert(1)", "page.html": "<p>", "img/a.png": "png"}, nil)
+	sh := &Vstat{Assets: assets, Cache: []Vccp{
+		{Pattern: "*.html", Value: CcNoCache},
+		{Pattern: "/img/", Value: "public, max-age=86400"},
+	}}
+	fp := testSha256("alert(1)")[:fpLen]
+
+	for _, c := range []struct {
+		target string
+		code   int
+		cache  string
+	}{
+		{"/app." + fp + ".js", 200, CcImmutable},
+		{"/app.0123456789.js", 200, CcNoCache}, // устаревший отпечаток - текущий файл
+		{"/app.js", 200, ""},
+		{"/page.html", 200, CcNoCache},
+		{"/img/a.png", 200, "public, max-age=86400"},
+		{"/none.0123456789.js", 404, ""},
+	} {
+		w := testServe(sh, "GET", c.target)
+		if w.Code != c.code || w.Header().Get("Cache-Control") != c.cache {
+			t.Errorf("%s: %d, Cache-Control %q; want %d, %q", c.target, w.Code, w.Header().Get("Cache-Control"), c.code, c.cache)
+		}
+	}
+}
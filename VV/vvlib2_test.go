@@ -0,0 +1,464 @@
+package vv
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// testGenRun генерация кода input в отдельный модуль и запуск в нем программы main (тело функции main);
+// возвращает вывод программы
+// - сгенерированный код компилируется по-настоящему: так проверяется сам шаблон vfsgen
+func testGenRun(t *testing.T, input http.FileSystem, opt Options, imports string, main string) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("compiles generated code")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+
+	dir := t.TempDir()
+	opt.Filename, opt.PackageName = filepath.Join(dir, "assets_vfsdata.go"), "main"
+	if err := Generate(input, opt); err != nil {
+		t.Fatal(err)
+	}
+	src := "package main\n\nimport (\n" + imports + "\n)\n\nfunc main() {\n" + main + "\n}\n"
+	for name, data := range map[string]string{"go.mod": "module vvgentest\n\ngo 1.23\n", "main.go": src} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(gobin, "run", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=-mod=mod")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run: %v\n%s", err, out)
+	}
+	return string(out)
+}
+
+// testBig сжимаемое содержимое в несколько кусков gzip
+func testBig() string {
+	var sb strings.Builder
+	for i := 0; sb.Len() < 100000; i++ {
+		fmt.Fprintf(&sb, "line %d\n", i)
+	}
+	return sb.String()
+}
+
+func TestGenerateCompressedSeek(t *testing.T) {
+	content := testBig()
+	input := fstest.MapFS{"big.txt": {Data: []byte(content)}}
+
+	out := testGenRun(t, http.FS(input), Options{}, `"fmt"
+	"io"`, `
+	f, err := assets.Open("/big.txt")
+	if err != nil {
+		panic(err)
+	}
+	if _, ok := f.(interface{ GzipBytes() []byte }); !ok {
+		panic("big.txt is not compressed")
+	}
+	buf := make([]byte, 10)
+	for _, s := range [][2]int64{{1000, io.SeekStart}, {-500, io.SeekCurrent}, {-10, io.SeekEnd}, {0, io.SeekStart}} {
+		pos, err := f.Seek(s[0], int(s[1]))
+		if err != nil {
+			panic(err)
+		}
+		n, _ := io.ReadFull(f, buf)
+		fmt.Printf("%d %q\n", pos, buf[:n])
+	}
+	pos, _ := f.Seek(1, io.SeekEnd)
+	n, err := f.Read(buf)
+	fmt.Printf("%d %d %v\n", pos, n, err)
+	all, _ := f.Seek(0, io.SeekStart)
+	b, err := io.ReadAll(f)
+	fmt.Println(all, len(b), err)`)
+
+	size := len(content)
+	want := fmt.Sprintf("1000 %q\n510 %q\n%d %q\n0 %q\n%d 0 EOF\n0 %d <nil>\n",
+		content[1000:1010], content[510:520], size-10, content[size-10:], content[:10], size+1, size)
+	if out != want {
+		t.Errorf("generated compressed file:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+// testTree небольшое дерево файлов: сжимаемые и несжимаемые файлы, вложенный каталог
+func testTree() fstest.MapFS {
+	return fstest.MapFS{
+		"index.html":    {Data: []byte(strings.Repeat("<p>hello</p>\n", 50)), ModTime: testMtime},
+		"img/logo.png":  {Data: []byte("\x89PNG raw bytes"), ModTime: testMtime},
+		"css/site.css":  {Data: []byte(strings.Repeat("body { color: red }\n", 50)), ModTime: testMtime},
+		"css/empty.css": {ModTime: testMtime},
+	}
+}
+
+func TestGenerateInput(t *testing.T) {
+	opt := Options{ZeroModTime: true}
+	fromFS, err := GenerateBytesFS(testTree(), opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromHTTP, err := GenerateBytes(http.FS(testTree()), opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fromFS, fromHTTP) {
+		t.Error("GenerateBytesFS and GenerateBytes of the same http.FS differ")
+	}
+
+	opt.Filename = filepath.Join(t.TempDir(), "assets_vfsdata.go")
+	if err := GenerateFS(testTree(), opt); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(opt.Filename); err != nil || !bytes.Equal(b, fromHTTP) {
+		t.Errorf("GenerateFS wrote other code than GenerateBytes, %v", err)
+	}
+}
+
+func TestReadFS(t *testing.T) {
+	fsys := testTree()
+	if b, err := ReadFileFS(fsys, "css/site.css"); err != nil || string(b) != string(fsys["css/site.css"].Data) {
+		t.Errorf("ReadFileFS = %q, %v", b, err)
+	}
+	if fi, err := StatFS(fsys, "img"); err != nil || !fi.IsDir() {
+		t.Errorf("StatFS(img) = %v, %v, want a directory", fi, err)
+	}
+	if fi, err := StatFS(fsys, "."); err != nil || !fi.IsDir() {
+		t.Errorf("StatFS(.) = %v, %v, want the root", fi, err)
+	}
+	if fis, err := ReadDirFS(fsys, "css"); err != nil || len(fis) != 2 {
+		t.Errorf("ReadDirFS(css) = %d entries, %v, want 2", len(fis), err)
+	}
+	if _, err := ReadFileFS(fsys, "none.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadFileFS(none.txt) = %v, want ErrNotExist", err)
+	}
+
+	// пути io/fs: без "/" в начале и в конце, без "." и ".." внутри
+	for _, name := range []string{"/index.html", "css/", "./index.html", "css/../index.html", ""} {
+		if _, err := ReadFileFS(fsys, name); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("ReadFileFS(%q) = %v, want ErrInvalid", name, err)
+		}
+		if _, err := StatFS(fsys, name); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("StatFS(%q) = %v, want ErrInvalid", name, err)
+		}
+		if _, err := ReadDirFS(fsys, name); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("ReadDirFS(%q) = %v, want ErrInvalid", name, err)
+		}
+	}
+}
+
+func TestGenerateIOFS(t *testing.T) {
+	out := testGenRun(t, http.FS(testTree()), Options{}, `"errors"
+	"fmt"
+	"io/fs"
+	"testing/fstest"`, `
+	if err := fstest.TestFS(assetsFS, "index.html", "img/logo.png", "css/site.css", "css/empty.css"); err != nil {
+		panic(err)
+	}
+	b, err := fs.ReadFile(assetsFS, "css/site.css")
+	fmt.Println(len(b), err)
+	des, err := fs.ReadDir(assetsFS, "css")
+	fmt.Println(len(des), err)
+	_, err = fs.ReadFile(assetsFS, "css")
+	fmt.Println(err != nil)
+	_, err = assetsFS.Open("/index.html")
+	fmt.Println(errors.Is(err, fs.ErrInvalid))
+	_, err = fs.Stat(assetsFS, "none")
+	fmt.Println(errors.Is(err, fs.ErrNotExist))`)
+
+	want := fmt.Sprintf("%d <nil>\n2 <nil>\ntrue\ntrue\ntrue\n", len(testTree()["css/site.css"].Data))
+	if out != want {
+		t.Errorf("generated io/fs view:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+// testEntryRe запись файла или каталога в сгенерированном коде: "/css/site.css": &vfsgen۰CompressedFileInfo{
+var testEntryRe = regexp.MustCompile(`(?m)^\t\t"([^"]*)": &vfsgen۰(\w+)Info\{`)
+
+// testEntries файлы и каталоги сгенерированного кода: путь -> "Dir", "File" (без сжатия) или "CompressedFile"
+func testEntries(b []byte) map[string]string {
+	m := make(map[string]string)
+	for _, sm := range testEntryRe.FindAllSubmatch(b, -1) {
+		m[string(sm[1])] = string(sm[2])
+	}
+	return m
+}
+
+func TestGlobMatch(t *testing.T) {
+	for _, c := range []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"*.css", "site.css", true},
+		{"*.css", "css/site.css", true}, // без "/" - по имени файла
+		{"*.css", "css", false},
+		{"css/*.css", "css/site.css", true},
+		{"css/*.css", "a/css/site.css", false}, // с "/" - по пути от корня
+		{"*/*.css", "css/site.css", true},
+		{"/index.html", "index.html", true}, // "/" в начале - только в корне
+		{"/index.html", "sub/index.html", false},
+		{"index.html", "sub/index.html", true},
+		{"[", "[", false}, // ошибка шаблона - не совпадает
+	} {
+		if got := globMatch(c.pattern, c.path); got != c.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", c.pattern, c.path, got, c.want)
+		}
+	}
+}
+
+func TestOptionsKeep(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		opt   Options
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"root", Options{Include: []string{"*.css"}, Exclude: []string{"*"}}, "/", true, true},
+		{"all", Options{}, "/img/logo.png", false, true},
+		{"include", Options{Include: []string{"*.css"}}, "/css/site.css", false, true},
+		{"not included", Options{Include: []string{"*.css"}}, "/index.html", false, false},
+		{"include keeps dirs", Options{Include: []string{"*.css"}}, "/img", true, true},
+		{"include by path", Options{Include: []string{"img/*"}}, "/img/logo.png", false, true},
+		{"anchored include", Options{Include: []string{"/index.html"}}, "/sub/index.html", false, false},
+		{"exclude", Options{Exclude: []string{"*.png"}}, "/img/logo.png", false, false},
+		{"exclude dir", Options{Exclude: []string{"img"}}, "/img", true, false},
+		{"exclude wins", Options{Include: []string{"*.css"}, Exclude: []string{"css/site.css"}}, "/css/site.css", false, false},
+		{"dotfile", Options{}, "/.git", true, false},
+		{"backup", Options{}, "/css/site.css~", false, false},
+		{"swap", Options{}, "/.site.css.swp", false, false},
+		{"no default exclude", Options{NoDefaultExclude: true}, "/.well-known", true, true},
+	} {
+		if got := c.opt.Keep(c.path, c.isDir); got != c.want {
+			t.Errorf("%s: Keep(%q) = %v, want %v", c.name, c.path, got, c.want)
+		}
+	}
+}
+
+func TestOptionsCompression(t *testing.T) {
+	rules := []CompressRule{
+		{Pattern: "*.svg", Mode: CompressAlways, Level: 5},
+		{Pattern: "raw/*", Mode: CompressNever},
+		{Pattern: "*.txt", Mode: CompressAuto, Level: 1},
+	}
+	for _, c := range []struct {
+		name  string
+		opt   Options
+		path  string
+		size  int64
+		mode  CompressMode
+		level int
+	}{
+		{"default", Options{}, "/site.css", 1000, CompressAuto, 9},
+		{"raw extension", Options{}, "/img/logo.PNG", 1000, CompressNever, 9},
+		{"own raw extensions", Options{RawExtensions: []string{".css"}}, "/site.css", 1000, CompressNever, 9},
+		{"no raw extensions", Options{RawExtensions: []string{}}, "/logo.png", 1000, CompressAuto, 9},
+		{"too small", Options{MinCompressSize: 100}, "/site.css", 99, CompressNever, 9},
+		{"too big", Options{MaxCompressSize: 100}, "/site.css", 101, CompressNever, 9},
+		{"in bounds", Options{MinCompressSize: 100, MaxCompressSize: 100}, "/site.css", 100, CompressAuto, 9},
+		{"always", Options{Compress: rules}, "/icon.svg", 1, CompressAlways, 5},
+		{"always raw extension", Options{Compress: []CompressRule{{Pattern: "*.png", Mode: CompressAlways}}}, "/logo.png", 1000, CompressAlways, 9},
+		{"never", Options{Compress: rules}, "/raw/site.css", 1000, CompressNever, 9},
+		{"auto with level", Options{Compress: rules}, "/a.txt", 1000, CompressAuto, 1},
+		{"auto rule, too small", Options{Compress: rules, MinCompressSize: 100}, "/a.txt", 10, CompressNever, 1},
+		{"first rule wins", Options{Compress: append([]CompressRule{{Pattern: "*", Mode: CompressNever}}, rules...)}, "/icon.svg", 1000, CompressNever, 9},
+		{"no rule", Options{Compress: rules}, "/site.css", 1000, CompressAuto, 9},
+	} {
+		mode, level := c.opt.Compression(c.path, c.size)
+		if mode != c.mode || level != c.level {
+			t.Errorf("%s: Compression(%q, %d) = %s, %d, want %s, %d", c.name, c.path, c.size, mode, level, c.mode, c.level)
+		}
+	}
+
+	for m, want := range map[CompressMode]string{CompressAuto: "auto", CompressNever: "never", CompressAlways: "always", 7: "CompressMode(7)"} {
+		if m.String() != want {
+			t.Errorf("CompressMode(%d).String() = %s, want %s", int(m), m, want)
+		}
+	}
+}
+
+func TestGenerateFilter(t *testing.T) {
+	tree := testTree()
+	tree[".git/config"] = &fstest.MapFile{Data: []byte("[core]")}
+	tree["css/site.css~"] = &fstest.MapFile{Data: []byte("old")}
+	tree["tiny.txt"] = &fstest.MapFile{Data: []byte(strings.Repeat("a", 100))}
+
+	b, err := GenerateBytesFS(tree, Options{
+		ZeroModTime: true,
+		Exclude:     []string{"img"},
+		Compress: []CompressRule{
+			{Pattern: "*.html", Mode: CompressNever},
+			{Pattern: "css/empty.css", Mode: CompressAlways},
+		},
+		MinCompressSize: 1000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := testEntries(b)
+	want := map[string]string{
+		"/":              "Dir",
+		"/css":           "Dir",
+		"/css/empty.css": "CompressedFile", // CompressAlways - даже пустой
+		"/css/site.css":  "CompressedFile",
+		"/index.html":    "File",
+		"/tiny.txt":      "File", // меньше MinCompressSize
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("generated entries:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestGeneratePrune(t *testing.T) {
+	tree := testTree()
+	tree["docs/a/b/readme.md"] = &fstest.MapFile{Data: []byte("# docs")}
+	tree["empty"] = &fstest.MapFile{Mode: fs.ModeDir} // пустой и во входной файловой системе
+
+	for _, c := range []struct {
+		name string
+		opt  Options
+		want []string
+	}{
+		{"include", Options{Include: []string{"*.css"}}, []string{"/", "/css", "/css/empty.css", "/css/site.css", "/empty"}},
+		{"include nested", Options{Include: []string{"*.md"}}, []string{"/", "/docs", "/docs/a", "/docs/a/b", "/docs/a/b/readme.md", "/empty"}},
+		{"exclude", Options{Exclude: []string{"*.png", "docs/a/b/*"}}, []string{"/", "/css", "/css/empty.css", "/css/site.css", "/empty", "/index.html"}},
+	} {
+		c.opt.ZeroModTime = true
+		b, err := GenerateBytesFS(tree, c.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for name := range testEntries(b) {
+			got = append(got, name)
+		}
+		sort.Strings(got)
+		if fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("%s: generated entries %v, want %v", c.name, got, c.want)
+		}
+		for _, dir := range []string{"/img", "/docs"} { // в списках каталогов их тоже нет
+			if !slices.Contains(c.want, dir) && bytes.Contains(b, []byte(`"`+dir+`"`)) {
+				t.Errorf("%s: pruned %s is listed in the generated code", c.name, dir)
+			}
+		}
+	}
+}
+
+func TestGenerateModTime(t *testing.T) {
+	// два checkout одного и того же дерева в разное время
+	a, b := testTree(), testTree()
+	for _, f := range b {
+		f.ModTime = testMtime.Add(time.Hour)
+	}
+	fixed := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, c := range []struct {
+		name  string
+		opt   Options
+		equal bool
+		time  string
+	}{
+		{"own times", Options{}, false, "time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)"},
+		{"ModTime", Options{ModTime: fixed}, true, "time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)"},
+		{"ModTime in another zone", Options{ModTime: fixed.In(time.FixedZone("X", 3600))}, true, "time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)"},
+		{"ZeroModTime", Options{ZeroModTime: true, ModTime: fixed}, true, "time.Time{}"},
+	} {
+		ga, err := GenerateBytesFS(a, c.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gb, err := GenerateBytesFS(b, c.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Equal(ga, gb) != c.equal {
+			t.Errorf("%s: output of different checkouts equal = %v, want %v", c.name, !c.equal, c.equal)
+		}
+		if !bytes.Contains(ga, []byte("modTime:          "+c.time)) {
+			t.Errorf("%s: no modTime %s in the output", c.name, c.time)
+		}
+	}
+}
+
+func TestGenerateUnchanged(t *testing.T) {
+	opt := Options{Filename: filepath.Join(t.TempDir(), "assets_vfsdata.go"), ZeroModTime: true}
+	if err := GenerateFS(testTree(), opt); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(opt.Filename, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := GenerateFS(testTree(), opt); err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ := os.Stat(opt.Filename); !fi.ModTime().Equal(old) {
+		t.Error("unchanged output was rewritten")
+	}
+
+	tree := testTree()
+	tree["new.txt"] = &fstest.MapFile{Data: []byte("new")}
+	if err := GenerateFS(tree, opt); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(opt.Filename); !bytes.Contains(b, []byte(`"/new.txt"`)) {
+		t.Error("changed output was not written")
+	}
+}
+
+func TestGenerateSha256(t *testing.T) {
+	tree := testTree()
+	out := testGenRun(t, http.FS(tree), Options{ZeroModTime: true}, `"encoding/hex"
+	"fmt"`, `
+	for _, name := range []string{"/index.html", "/img/logo.png", "/css/empty.css"} {
+		f, err := assets.Open(name)
+		if err != nil {
+			panic(err)
+		}
+		fi, _ := f.Stat()
+		sum := fi.(interface{ Sha256() [32]byte }).Sha256()
+		fp := fi.(interface{ Fingerprint() string }).Fingerprint()
+		fmt.Println(hex.EncodeToString(sum[:]), fp, fi.ModTime().IsZero())
+	}`)
+
+	var want strings.Builder
+	for _, name := range []string{"index.html", "img/logo.png", "css/empty.css"} {
+		sum := testSha256(string(tree[name].Data))
+		fmt.Fprintln(&want, sum, sum, true)
+	}
+	if out != want.String() {
+		t.Errorf("generated Sha256 and Fingerprint:\n%s\nwant:\n%s", out, want.String())
+	}
+
+	// хеш записан в код готовым массивом: сгенерированный код ничего не декодирует при работе
+	b, err := GenerateBytesFS(tree, Options{ZeroModTime: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(tree["img/logo.png"].Data)
+	if lit := fmt.Sprintf("sha256:      [32]byte{0x%02x, 0x%02x,", sum[0], sum[1]); !bytes.Contains(b, []byte(lit)) {
+		t.Errorf("no %q in the generated code", lit)
+	}
+	if bytes.Contains(b, []byte("hex.")) {
+		t.Error("generated code uses encoding/hex")
+	}
+}
@@ -31,6 +31,7 @@ func ListenAndServeTLS(srv *http.Server, certPEMBlock []byte, keyPEMBlock []byte
 		Vlogger.Vlog(0, err.Error(), 1)
 		return err
 	}
+	vtlsCert(config.Certificates[0]) // срок действия сертификата - для HealthHandler (см. vvlib17.go)
 
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
@@ -0,0 +1,249 @@
+package vv
+
+// Ротация файла лога:
+// - по размеру: как только очередная запись не помещается в Vlg.MaxSize, файл ротируется
+// - по календарю: при первой записи в новом часе/сутках (Vlg.Period) файл ротируется
+// - ротированный файл получает имя <имя>-<дата-время><расширение>, например log-20261017-150405.000.txt
+// - ротированные файлы можно сжимать в .gz и чистить по количеству (MaxFiles) и возрасту (MaxAge)
+// Ротацию выполняет сам воркер логгера между двумя записями, поэтому события, ждущие в канале VcLog, не теряются.
+// Основной файл лога (Vlg.File) и дополнительные файловые приемники FileSink ротируются одинаково.
+
+import (
+	"compress/gzip"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileSink приемник лога: текстовый файл с ротацией
+type FileSink struct {
+	File     string        // полное имя файла лога
+	Format   string        // формат строк: "" или "text" - текст, "json" - JSON Lines
+	MaxSize  int64         // max размер файла лога, байт (0 - без ограничения)
+	Period   string        // ротация по календарю: "" - нет, "hourly" - каждый час, "daily" - каждые сутки
+	MaxFiles int           // сколько ротированных файлов хранить (0 - все)
+	MaxAge   time.Duration // max возраст ротированных файлов (0 - без ограничения)
+	Gzip     bool          // сжимать ротированные файлы в .gz
+
+	lf vlfile // открытый файл и состояние ротации
+}
+
+// vlfile файл лога с ротацией
+type vlfile struct {
+	name     string        // полное имя текущего файла лога
+	maxSize  int64         // max размер файла, байт (0 - без ограничения)
+	period   string        // ротация по календарю: "", "hourly", "daily"
+	maxFiles int           // сколько ротированных файлов хранить (0 - все)
+	maxAge   time.Duration // max возраст ротированных файлов (0 - без ограничения)
+	gzip     bool          // сжимать ротированные файлы
+
+	f      *os.File // открытый текущий файл
+	size   int64    // текущий размер файла
+	pstart string   // календарный период, в котором открыт файл
+}
+
+var vlrotmu sync.Mutex // сериализация фоновых сжатия и чистки ротированных файлов
+
+// NewFileSink -----------------------------------------------------------------------------
+// файловый приемник лога без ротации; параметры ротации можно задать в полях до AddSink
+func NewFileSink(file string) *FileSink {
+	return &FileSink{File: file}
+}
+
+// Write -----------------------------------------------------------------------------
+func (fs *FileSink) Write(vle Vle) error {
+	fs.lf.config(fs) // параметры могли измениться после подключения приемника
+	return fs.lf.write(vle.format(fs.Format))
+}
+
+// Flush -----------------------------------------------------------------------------
+// запись в файл не буферизуется, сбрасывать нечего
+func (fs *FileSink) Flush() error { return nil }
+
+// Close -----------------------------------------------------------------------------
+func (fs *FileSink) Close() error { return fs.lf.close() }
+
+// config -----------------------------------------------------------------------------
+// перечитывание параметров из FileSink; при смене имени файла текущий файл закрывается,
+// а новый открывается при следующей записи
+func (lf *vlfile) config(fs *FileSink) {
+	if fs.File != lf.name {
+		lf.close()
+		lf.name = fs.File
+	}
+	lf.maxSize = fs.MaxSize
+	lf.period = fs.Period
+	lf.maxFiles = fs.MaxFiles
+	lf.maxAge = fs.MaxAge
+	lf.gzip = fs.Gzip
+}
+
+// open -----------------------------------------------------------------------------
+// открытие (создание) текущего файла лога в режиме добавления
+func (lf *vlfile) open() error {
+	f, err := os.OpenFile(lf.name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	if fi, er := f.Stat(); er == nil {
+		size = fi.Size()
+	}
+
+	lf.f, lf.size, lf.pstart = f, size, lf.periodOf(time.Now())
+	return nil
+}
+
+// write -----------------------------------------------------------------------------
+// запись строки в файл лога с предварительной ротацией при необходимости
+func (lf *vlfile) write(s string) error {
+	if lf.f == nil { // файл не открылся при старте или после ротации - пробуем еще раз
+		if err := lf.open(); err != nil {
+			return err
+		}
+	}
+
+	if lf.needRotate(int64(len(s))) {
+		if err := lf.rotate(); err != nil {
+			log.Println("Log file: rotate error:", err) // продолжаем писать в прежний файл
+		}
+	}
+
+	n, err := lf.f.WriteString(s)
+	lf.size += int64(n)
+	return err
+}
+
+// close -----------------------------------------------------------------------------
+func (lf *vlfile) close() error {
+	if lf.f == nil {
+		return nil
+	}
+	err := lf.f.Close()
+	lf.f = nil
+	return err
+}
+
+// needRotate -----------------------------------------------------------------------------
+// нужна ли ротация перед записью n байт
+func (lf *vlfile) needRotate(n int64) bool {
+	if lf.maxSize > 0 && lf.size > 0 && lf.size+n > lf.maxSize {
+		return true
+	}
+	return lf.period != "" && lf.periodOf(time.Now()) != lf.pstart
+}
+
+// periodOf -----------------------------------------------------------------------------
+// календарный период момента t для сравнения при ротации по времени
+func (lf *vlfile) periodOf(t time.Time) string {
+	switch lf.period {
+	case "hourly":
+		return t.Format("2006010215")
+	case "daily":
+		return t.Format("20060102")
+	}
+	return ""
+}
+
+// rotate -----------------------------------------------------------------------------
+// ротация: текущий файл закрывается и переименовывается, на его месте открывается новый
+// - файл закрываем до переименования: под Windows открытый файл переименовать нельзя
+// - если новый файл открыть не удалось, возвращаем старому прежнее имя и пишем дальше в него
+func (lf *vlfile) rotate() error {
+	ext := filepath.Ext(lf.name)
+	rname := strings.TrimSuffix(lf.name, ext) + "-" + time.Now().Format("20060102-150405.000") + ext
+
+	lf.close()
+	if err := os.Rename(lf.name, rname); err != nil {
+		lf.open()
+		return err
+	}
+
+	if err := lf.open(); err != nil {
+		os.Rename(rname, lf.name)
+		lf.open()
+		return err
+	}
+
+	go cleanupLogs(lf.name, rname, lf.gzip, lf.maxFiles, lf.maxAge)
+	return nil
+}
+
+// cleanupLogs -----------------------------------------------------------------------------
+// фоновая обработка ротированных файлов лога name: сжатие rname и удаление лишних/устаревших
+func cleanupLogs(name string, rname string, gz bool, maxFiles int, maxAge time.Duration) {
+	vlrotmu.Lock()
+	defer vlrotmu.Unlock()
+
+	if gz {
+		if err := gzipFile(rname); err != nil {
+			log.Println("Log file: gzip error:", err)
+		}
+	}
+
+	if maxFiles <= 0 && maxAge <= 0 {
+		return
+	}
+
+	// ротированные файлы: <имя>-*<расширение>[.gz]; имена содержат время, поэтому сортировка = хронология
+	ext := filepath.Ext(name)
+	prefix := strings.TrimSuffix(name, ext) + "-"
+	names, _ := filepath.Glob(prefix + "*" + ext)
+	gzs, _ := filepath.Glob(prefix + "*" + ext + ".gz")
+	names = append(names, gzs...)
+	sort.Slice(names, func(i, j int) bool {
+		return strings.TrimSuffix(names[i], ".gz") > strings.TrimSuffix(names[j], ".gz")
+	})
+
+	for i, rn := range names {
+		expired := maxFiles > 0 && i >= maxFiles
+		if !expired && maxAge > 0 {
+			if fi, err := os.Stat(rn); err == nil && time.Since(fi.ModTime()) > maxAge {
+				expired = true
+			}
+		}
+		if expired {
+			if err := os.Remove(rn); err != nil {
+				log.Println("Log file: remove error:", err)
+			}
+		}
+	}
+}
+
+// gzipFile -----------------------------------------------------------------------------
+// сжатие файла name в name.gz с удалением исходного
+func gzipFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	gw := gzip.NewWriter(dst)
+	gw.Name = filepath.Base(name)
+	_, err = io.Copy(gw, src)
+	if er := gw.Close(); err == nil {
+		err = er
+	}
+	if er := dst.Close(); err == nil {
+		err = er
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+
+	src.Close()
+	return os.Remove(name)
+}
@@ -0,0 +1,143 @@
+package vv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testVle событие для тестов лога
+func testVle(estr string) Vle {
+	return Vle{pid: 1, etype: Einf, etime: time.Now(), gfile: "x.go", line: 1, funame: "vv.test", estr: estr}
+}
+
+// testRotated ротированные файлы лога name (сжатые и нет)
+func testRotated(t *testing.T, name string) []string {
+	t.Helper()
+	ext := filepath.Ext(name)
+	names, err := filepath.Glob(strings.TrimSuffix(name, ext) + "-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+// testEventually ждет выполнения условия фоновой работы: чистки ротированных файлов, дочитывания сброса лога
+func testEventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for end := time.Now().Add(5 * time.Second); !cond(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(end) {
+			t.Fatal("timeout waiting for " + what)
+		}
+	}
+}
+
+func TestFileSinkRotateSize(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	fs := &FileSink{File: name, MaxSize: 300}
+
+	const n = 10
+	for i := 0; i < n; i++ {
+		if err := fs.Write(testVle(strings.Repeat("x", 50))); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond) // имена ротированных файлов различаются по миллисекундам
+	}
+	if err := fs.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := testRotated(t, name)
+	if len(rotated) == 0 {
+		t.Fatal("no rotated files")
+	}
+
+	var all []byte
+	for _, fn := range append(rotated, name) {
+		b, err := os.ReadFile(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) > 300 {
+			t.Errorf("%s: size %d > MaxSize", filepath.Base(fn), len(b))
+		}
+		all = append(all, b...)
+	}
+	vles, err := ReadLog(bytes.NewReader(all))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vles) != n {
+		t.Errorf("events after rotation: got %d, want %d", len(vles), n)
+	}
+}
+
+func TestFileSinkRetention(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	fs := &FileSink{File: name, MaxSize: 1, MaxFiles: 2, Gzip: true} // ротация перед каждой записью, кроме первой
+
+	for i := 0; i < 6; i++ {
+		if err := fs.Write(testVle("event")); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	fs.Close()
+
+	testEventually(t, "2 gzipped rotated files", func() bool {
+		rotated := testRotated(t, name)
+		for _, fn := range rotated {
+			if !strings.HasSuffix(fn, ".gz") {
+				return false
+			}
+		}
+		return len(rotated) == 2
+	})
+
+	for _, fn := range testRotated(t, name) {
+		f, err := os.Open(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(fn, err)
+		}
+		b, err := io.ReadAll(zr)
+		f.Close()
+		if err != nil || !strings.Contains(string(b), "event") {
+			t.Errorf("%s: bad gzip content %q, %v", filepath.Base(fn), b, err)
+		}
+	}
+}
+
+func TestLogPeriod(t *testing.T) {
+	tm := time.Date(2026, 10, 17, 15, 4, 5, 0, time.Local)
+	for _, c := range []struct {
+		period string
+		want   string
+	}{
+		{"", ""},
+		{"hourly", "2026101715"},
+		{"daily", "20261017"},
+	} {
+		lf := vlfile{period: c.period}
+		if got := lf.periodOf(tm); got != c.want {
+			t.Errorf("periodOf(%q) = %q, want %q", c.period, got, c.want)
+		}
+	}
+
+	lf := vlfile{period: "hourly", pstart: "2000010100"}
+	if !lf.needRotate(1) {
+		t.Error("needRotate: want rotation on a new period")
+	}
+	lf = vlfile{maxSize: 100, size: 0}
+	if lf.needRotate(1000) {
+		t.Error("needRotate: empty file must not rotate even if the line is larger than MaxSize")
+	}
+}
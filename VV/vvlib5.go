@@ -0,0 +1,357 @@
+package vv
+
+// Приемники лога (sinks):
+// - воркер логгера отдает каждое событие из канала VcLog всем приемникам лога
+// - основной файл лога Vlg.File - приемник по умолчанию, уровень для него задает Vlg.Level
+// - дополнительные приемники подключаются через AddSink, каждый со своим уровнем логирования
+// - встроенные приемники: FileSink (файл с ротацией), StderrSink (консоль), RingSink (кольцевой буфер в памяти)
+//   и DbSink (таблица Logger базы данных Dba, пакетная вставка)
+// - раз в секунду логгер вызывает Flush у всех приемников, чтобы пакетные приемники не держали события долго
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Vsink приемник событий лога
+// - методы вызываются только из воркера логгера, поэтому приемнику не нужна своя синхронизация записи
+type Vsink interface {
+	Write(vle Vle) error // запись события
+	Flush() error        // сброс накопленных событий
+	Close() error        // закрытие приемника
+}
+
+// vsink подключенный приемник лога
+type vsink struct {
+	name  string // имя приемника
+	sink  Vsink  // приемник
+	level int    // уровень логирования приемника
+}
+
+// Pid -----------------------------------------------------------------------------
+// чтение полей события приложения для внешних приемников
+func (vle Vle) Pid() uint64      { return vle.pid }    // id обработки
+func (vle Vle) Event() int       { return vle.event }  // код события
+func (vle Vle) Pname() string    { return vle.pname }  // имя процедуры
+func (vle Vle) Prc() int         { return vle.prc }    // % выполнения обработки
+func (vle Vle) Estr() string     { return vle.estr }   // текст события
+func (vle Vle) Etime() time.Time { return vle.etime }  // время события
+func (vle Vle) Gfile() string    { return vle.gfile }  // go-файл
+func (vle Vle) Funame() string   { return vle.funame } // имя функции
+func (vle Vle) Line() int        { return vle.line }   // строка кода
+func (vle Vle) Etype() int       { return vle.etype }  // тип события
+
+// text -----------------------------------------------------------------------------
+// строка события для текстового лога
+func (vle Vle) text() string {
+	return vle.etime.Format("2006-01-02 15:04:05.000") + " p" + // дата, время
+		RPads(strconv.FormatUint(vle.pid, 10), 8) + " " + // id обработки
+		Etname(vle.etype) + " " + // тип события
+		RPads(filepath.Base(vle.gfile), 10) + " " + // имя go-файла
+		fmt.Sprintf("%5d", vle.line) + " " +
+		RPads(vle.funame, 15) + " " + // имя функции
+		vle.estr + "\r\n" // текст события
+}
+
+// AddSink -----------------------------------------------------------------------------
+// подключение приемника лога
+// - name  - имя приемника, повторное подключение с тем же именем заменяет (и закрывает) прежний приемник
+// - level - уровень логирования приемника: Lerr, Lwrn, Linf, Ldbg
+// - Vlg.Level и переопределения SetLevel задают уровень только основного файла лога, на приемники не влияют
+func (vl *Vlg) AddSink(name string, sink Vsink, level int) {
+	vl.smu.Lock()
+	defer vl.smu.Unlock()
+	defer vl.sinkLevel()
+
+	for i := range vl.sinks {
+		if vl.sinks[i].name == name {
+			vl.sinks[i].sink.Close()
+			vl.sinks[i] = vsink{name: name, sink: sink, level: level}
+			return
+		}
+	}
+	vl.sinks = append(vl.sinks, vsink{name: name, sink: sink, level: level})
+}
+
+// DelSink -----------------------------------------------------------------------------
+// отключение приемника лога: накопленное сбрасывается, приемник закрывается
+func (vl *Vlg) DelSink(name string) error {
+	vl.smu.Lock()
+	defer vl.smu.Unlock()
+
+	for i := range vl.sinks {
+		if vl.sinks[i].name == name {
+			s := vl.sinks[i].sink
+			vl.sinks = append(vl.sinks[:i], vl.sinks[i+1:]...)
+			vl.sinkLevel()
+			err := s.Flush()
+			if er := s.Close(); err == nil {
+				err = er
+			}
+			return err
+		}
+	}
+	return errors.New("Log sink not found: " + name)
+}
+
+// direct -----------------------------------------------------------------------------
+// запись события мимо канала лога: сразу в стандартный поток ошибок (Eftl) и в приемники запущенного лога
+// - для отчетов о панике: канал может быть заполнен, а его воркер - упавший логгер, и Vlog ждал бы вечно
+// - паника приемника при такой записи перехватывается, о ней сообщается в стандартный лог Go
+func (vl *Vlg) direct(pid uint64, estr string, etype int) {
+	pc, file, line, _ := runtime.Caller(1)
+	vle := Vle{pid: pid, estr: estr, etype: etype, etime: time.Now(), gfile: file, line: line}
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		vle.funame = fn.Name()
+	}
+	if pr, ok := Progress(pid); ok {
+		vle.pname, vle.prc = pr.Pname, pr.Prc
+	}
+
+	if etype == Eftl {
+		os.Stderr.WriteString(strings.TrimRight(vle.text(), "\r\n") + "\n")
+	}
+
+	vl.mu.RLock()
+	running := vl.vc != nil
+	vl.mu.RUnlock()
+	if !running {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("Log: panic while writing directly:", r)
+		}
+	}()
+	vl.write(vle)
+}
+
+// sinkLevel -----------------------------------------------------------------------------
+// пересчет max уровня приемников для фильтра событий в vlog (вызывается под smu)
+func (vl *Vlg) sinkLevel() {
+	level := Loff
+	for _, s := range vl.sinks {
+		level = max(level, s.level)
+	}
+	vl.slevel.Store(int32(level))
+}
+
+// write -----------------------------------------------------------------------------
+// запись события во все приемники: вызывается воркером логгера
+// - vlog пропускает событие по самому подробному из уровней, поэтому уровень каждого приемника проверяется здесь
+func (vl *Vlg) write(vle Vle) {
+	vl.smu.Lock()
+	defer vl.smu.Unlock()
+
+	// основной файл лога: параметры берем из Vlg, они могли измениться после старта логгера
+	if vl.File != "" && Elevel(vle.etype, vl.LevelOf(vle.funame)) {
+		vl.file.File, vl.file.Format, vl.file.MaxSize, vl.file.Period = vl.File, vl.Format, vl.MaxSize, vl.Period
+		vl.file.MaxFiles, vl.file.MaxAge, vl.file.Gzip = vl.MaxFiles, vl.MaxAge, vl.Gzip
+		if err := vl.file.Write(vle); err != nil {
+			vl.fail("Log file: write error:", err)
+		}
+	}
+
+	for _, s := range vl.sinks {
+		if !Elevel(vle.etype, s.level) {
+			continue
+		}
+		if err := s.sink.Write(vle); err != nil {
+			vl.fail("Log sink "+s.name+": write error:", err)
+		}
+	}
+}
+
+// flush -----------------------------------------------------------------------------
+// сброс накопленных событий во всех приемниках
+func (vl *Vlg) flush() {
+	vl.smu.Lock()
+	defer vl.smu.Unlock()
+
+	for _, s := range vl.sinks {
+		if err := s.sink.Flush(); err != nil {
+			vl.fail("Log sink "+s.name+": flush error:", err)
+		}
+	}
+}
+
+// vlerr ошибка записи в приемник лога и ее время
+type vlerr struct {
+	err error
+	t   time.Time
+}
+
+// fail -----------------------------------------------------------------------------
+// ошибка записи в приемник: в стандартный лог Go (писать ее в сам Vlg некуда) и в LastError
+func (vl *Vlg) fail(what string, err error) {
+	log.Println(what, err)
+	vl.lerr.Store(&vlerr{err: errors.New(what + " " + err.Error()), t: time.Now()})
+}
+
+// LastError -----------------------------------------------------------------------------
+// время и последняя ошибка записи в файл лога или приемники (nil - ошибок не было)
+func (vl *Vlg) LastError() (time.Time, error) {
+	if le := vl.lerr.Load(); le != nil {
+		return le.t, le.err
+	}
+	return time.Time{}, nil
+}
+
+// close -----------------------------------------------------------------------------
+// сброс и закрытие всех приемников при остановке логгера
+func (vl *Vlg) close() (err error) {
+	vl.smu.Lock()
+	defer vl.smu.Unlock()
+
+	if er := vl.file.Close(); er != nil {
+		err = er
+	}
+	for _, s := range vl.sinks {
+		if er := s.sink.Flush(); er != nil && err == nil {
+			err = er
+		}
+		if er := s.sink.Close(); er != nil && err == nil {
+			err = er
+		}
+	}
+	return err
+}
+
+// StderrSink -----------------------------------------------------------------------------
+// приемник лога: стандартный поток ошибок
+type StderrSink struct {
+	Format string // формат строк: "" или "text" - текст, "json" - JSON Lines
+}
+
+func (ss StderrSink) Write(vle Vle) error {
+	_, err := os.Stderr.WriteString(strings.TrimRight(vle.format(ss.Format), "\r\n") + "\n")
+	return err
+}
+func (StderrSink) Flush() error { return nil }
+func (StderrSink) Close() error { return nil }
+
+// RingSink -----------------------------------------------------------------------------
+// приемник лога: кольцевой буфер последних событий в памяти (например, для просмотра из web-интерфейса)
+type RingSink struct {
+	mu   sync.Mutex
+	buf  []Vle // кольцевой буфер
+	next int   // позиция следующей записи
+	full bool  // буфер заполнен хотя бы один раз
+}
+
+// NewRingSink -----------------------------------------------------------------------------
+// кольцевой буфер на n последних событий
+func NewRingSink(n int) *RingSink {
+	if n <= 0 {
+		n = 1000
+	}
+	return &RingSink{buf: make([]Vle, n)}
+}
+
+func (rs *RingSink) Write(vle Vle) error {
+	rs.mu.Lock()
+	rs.buf[rs.next] = vle
+	rs.next++
+	if rs.next == len(rs.buf) {
+		rs.next, rs.full = 0, true
+	}
+	rs.mu.Unlock()
+	return nil
+}
+func (rs *RingSink) Flush() error { return nil }
+func (rs *RingSink) Close() error { return nil }
+
+// Events -----------------------------------------------------------------------------
+// копия событий буфера: от старых к новым
+func (rs *RingSink) Events() []Vle {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if !rs.full {
+		return append([]Vle(nil), rs.buf[:rs.next]...)
+	}
+	return append(append([]Vle(nil), rs.buf[rs.next:]...), rs.buf[:rs.next]...)
+}
+
+// DbSink -----------------------------------------------------------------------------
+// приемник лога: таблица базы данных Dba
+// - события копятся в пакете и вставляются одним insert-запросом при заполнении пакета или по Flush
+// - колонки таблицы: PID, EVENT, PNAME, PRC, ETYPE, ETIME, GFILE, FUNAME, LINE, ESTR
+// - при ошибке вставки пакет отбрасывается, чтобы недоступная база не копила события в памяти бесконечно
+// - вставка ограничена по времени Timeout: зависшая база не останавливает логгер, пакет по таймауту отбрасывается
+type DbSink struct {
+	Table   string        // имя таблицы лога, по умолчанию Logger
+	Batch   int           // размер пакета вставки, по умолчанию 100
+	Timeout time.Duration // max длительность вставки пакета, по умолчанию 5 секунд
+
+	buf []Vle // накопленные события
+}
+
+// NewDbSink -----------------------------------------------------------------------------
+// приемник лога в таблицу Logger базы данных Dba
+func NewDbSink() *DbSink {
+	return &DbSink{Table: "Logger", Batch: 100, Timeout: 5 * time.Second}
+}
+
+func (ds *DbSink) Write(vle Vle) error {
+	ds.buf = append(ds.buf, vle)
+	if len(ds.buf) >= ds.Batch || ds.Batch <= 0 {
+		return ds.Flush()
+	}
+	return nil
+}
+
+// Flush -----------------------------------------------------------------------------
+// вставка накопленного пакета в таблицу
+// - запись идет напрямую через Dba, а не через Qexe: ошибки Qexe сами логируются и зациклили бы логгер
+func (ds *DbSink) Flush() error {
+	if len(ds.buf) == 0 {
+		return nil
+	}
+	buf := ds.buf
+	ds.buf = ds.buf[:0]
+
+	if Dba == nil {
+		return errors.New("DB is not opened: " + strconv.Itoa(len(buf)) + " events lost")
+	}
+
+	table := ds.Table
+	if table == "" {
+		table = "Logger"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("insert into " + table + " (PID, EVENT, PNAME, PRC, ETYPE, ETIME, GFILE, FUNAME, LINE, ESTR) values ")
+	args := make([]interface{}, 0, len(buf)*10)
+	for i, vle := range buf {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		args = append(args, vle.pid, vle.event, vle.pname, vle.prc, vle.etype, vle.etime,
+			filepath.Base(vle.gfile), vle.funame, vle.line, vle.estr)
+	}
+
+	timeout := ds.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if _, err := Dba.ExecContext(ctx, sb.String(), args...); err != nil {
+		return fmt.Errorf("DB insert error: %d events lost: %w", len(buf), err)
+	}
+	return nil
+}
+
+func (ds *DbSink) Close() error { return ds.Flush() }
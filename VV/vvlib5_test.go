@@ -0,0 +1,177 @@
+package vv
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRingSink(t *testing.T) {
+	rs := NewRingSink(3)
+	for _, s := range []string{"a", "b", "c", "d", "e"} {
+		rs.Write(testVle(s))
+	}
+
+	var got []string
+	for _, vle := range rs.Events() {
+		got = append(got, vle.Estr())
+	}
+	if strings.Join(got, "") != "cde" {
+		t.Errorf("Events = %v, want [c d e]", got)
+	}
+}
+
+func TestSinkLevels(t *testing.T) {
+	rt := NewVrt()
+	rt.Log.Level = Lerr
+	rt.Log.File = filepath.Join(t.TempDir(), "app.log")
+	dbg, inf := NewRingSink(10), NewRingSink(10)
+	rt.Log.AddSink("dbg", dbg, Ldbg)
+	rt.Log.AddSink("inf", inf, Linf)
+
+	if err := rt.Start(Vcfg{Quiet: true}); err != nil {
+		t.Fatal(err)
+	}
+	rt.Log.Vlog(1, "debug event", Edbg)
+	rt.Log.Vlog(1, "info event", Einf)
+	rt.Log.Vlog(1, "error event", Eerr)
+	if err := rt.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	has := func(vles []Vle, estr string) bool {
+		for _, vle := range vles {
+			if vle.Estr() == estr {
+				return true
+			}
+		}
+		return false
+	}
+	b, err := os.ReadFile(rt.Log.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		sink string
+		got  bool
+		want bool
+	}{
+		{"dbg: debug", has(dbg.Events(), "debug event"), true}, // уровень приемника выше Vlg.Level
+		{"dbg: error", has(dbg.Events(), "error event"), true},
+		{"inf: debug", has(inf.Events(), "debug event"), false},
+		{"inf: info", has(inf.Events(), "info event"), true},
+		{"file: debug", strings.Contains(string(b), "debug event"), false},
+		{"file: info", strings.Contains(string(b), "info event"), false},
+		{"file: error", strings.Contains(string(b), "error event"), true},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s: delivered = %v, want %v", c.sink, c.got, c.want)
+		}
+	}
+}
+
+func TestDelSinkLevel(t *testing.T) {
+	vl := &Vlg{}
+	vl.AddSink("a", NewRingSink(1), Ldbg)
+	vl.AddSink("b", NewRingSink(1), Lerr)
+	if got := vl.slevel.Load(); got != Ldbg {
+		t.Errorf("sink level = %d, want %d", got, Ldbg)
+	}
+	if err := vl.DelSink("a"); err != nil {
+		t.Fatal(err)
+	}
+	if got := vl.slevel.Load(); got != Lerr {
+		t.Errorf("sink level after DelSink = %d, want %d", got, Lerr)
+	}
+	if err := vl.DelSink("a"); err == nil {
+		t.Error("DelSink of a missing sink: want error")
+	}
+}
+
+// testDb база данных с драйвером testHangDriver на время теста вместо Dba
+func testDb(t *testing.T, drv *testHangDriver) {
+	name := "vvhang" + strconv.Itoa(int(testDbs.Add(1)))
+	sql.Register(name, drv)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := Dba
+	Dba = db
+	t.Cleanup(func() {
+		Dba = prev
+		db.Close()
+	})
+}
+
+var testDbs atomic.Int32 // счетчик имен зарегистрированных тестовых драйверов
+
+// testHangDriver драйвер sql, который зависает на exec, пока запрос не отменят; exec считает
+type testHangDriver struct {
+	hang  atomic.Bool
+	execs atomic.Int32
+	args  atomic.Int32 // аргументов в последнем exec
+}
+
+func (d *testHangDriver) Open(string) (driver.Conn, error) { return &testHangConn{d}, nil }
+
+type testHangConn struct{ d *testHangDriver }
+
+func (c *testHangConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *testHangConn) Close() error                        { return nil }
+func (c *testHangConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c *testHangConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.d.execs.Add(1)
+	c.d.args.Store(int32(len(args)))
+	if c.d.hang.Load() {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return driver.RowsAffected(len(args) / 10), nil
+}
+
+func TestDbSink(t *testing.T) {
+	drv := &testHangDriver{}
+	testDb(t, drv)
+
+	ds := NewDbSink()
+	ds.Batch = 3
+	ds.Timeout = 50 * time.Millisecond
+	for _, s := range []string{"a", "b"} {
+		if err := ds.Write(testVle(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if drv.execs.Load() != 0 {
+		t.Fatal("batch inserted before it was full")
+	}
+	if err := ds.Write(testVle("c")); err != nil || drv.execs.Load() != 1 || drv.args.Load() != 30 {
+		t.Fatalf("full batch: %v, %d execs, %d args, want one insert of 3 rows", err, drv.execs.Load(), drv.args.Load())
+	}
+
+	// зависшая база: Flush возвращается по Timeout, а пакет отбрасывается
+	drv.hang.Store(true)
+	ds.Write(testVle("d"))
+	start := time.Now()
+	err := ds.Flush()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Flush on a hung DB = %v, want DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Flush on a hung DB took %v", d)
+	}
+	drv.hang.Store(false)
+	if err := ds.Flush(); err != nil || drv.execs.Load() != 2 {
+		t.Errorf("Flush after timeout: %v, %d execs, want the batch dropped", err, drv.execs.Load())
+	}
+}
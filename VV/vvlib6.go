@@ -0,0 +1,247 @@
+package vv
+
+// Форматы лога:
+// - "text" (по умолчанию) - прежняя строка фиксированной ширины: удобно читать глазами, но разбирается не всегда надежно
+// - "json" - JSON Lines: одно событие = один JSON-объект в строке, все поля Vle, включая event, pname и prc
+// Формат задается полем Format у Vlg (основной файл лога), FileSink и StderrSink.
+// ParseLog/ScanLog/ReadLog читают оба формата обратно в события Vle, формат определяется по каждой строке.
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// vlej событие лога в формате JSON
+type vlej struct {
+	Pid    uint64    `json:"pid"`    // id обработки
+	Event  int       `json:"event"`  // код события
+	Pname  string    `json:"pname"`  // имя процедуры
+	Prc    int       `json:"prc"`    // % выполнения обработки
+	Estr   string    `json:"estr"`   // текст события
+	Etime  time.Time `json:"etime"`  // время события
+	Gfile  string    `json:"gfile"`  // go-файл
+	Funame string    `json:"funame"` // имя функции
+	Line   int       `json:"line"`   // строка кода
+	Etype  int       `json:"etype"`  // тип события
+	Etname string    `json:"etname"` // тип события: имя (Inf, Err, ...), только для удобства чтения
+}
+
+// format -----------------------------------------------------------------------------
+// строка события в заданном формате: "json" или текст
+func (vle Vle) format(format string) string {
+	if format == "json" {
+		return vle.json()
+	}
+	return vle.text()
+}
+
+// json -----------------------------------------------------------------------------
+// строка события в формате JSON Lines
+func (vle Vle) json() string {
+	b, err := json.Marshal(vlej{
+		Pid:    vle.pid,
+		Event:  vle.event,
+		Pname:  vle.pname,
+		Prc:    vle.prc,
+		Estr:   vle.estr,
+		Etime:  vle.etime,
+		Gfile:  vle.gfile,
+		Funame: vle.funame,
+		Line:   vle.line,
+		Etype:  vle.etype,
+		Etname: Etname(vle.etype),
+	})
+	if err != nil { // в событии только строки и числа, ошибок быть не должно
+		return `{"estr":` + strconv.Quote("Log: json error: "+err.Error()) + "}\n"
+	}
+	return string(b) + "\n"
+}
+
+// ParseLog -----------------------------------------------------------------------------
+// разбор одной строки лога в событие
+// - строка, начинающаяся с '{', разбирается как JSON, иначе как текстовая строка фиксированной ширины
+// - из текстовой строки восстанавливаются не все поля: go-файл только по имени, event, pname и prc там не пишутся
+func ParseLog(line string) (Vle, error) {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(strings.TrimLeft(line, " \t"), "{") {
+		return parseLogJSON(line)
+	}
+	return parseLogText(line)
+}
+
+// parseLogJSON -----------------------------------------------------------------------------
+func parseLogJSON(line string) (Vle, error) {
+	var j vlej
+	if err := json.Unmarshal([]byte(line), &j); err != nil {
+		return Vle{}, err
+	}
+
+	return Vle{
+		pid:    j.Pid,
+		event:  j.Event,
+		pname:  j.Pname,
+		prc:    j.Prc,
+		estr:   j.Estr,
+		etime:  j.Etime,
+		gfile:  j.Gfile,
+		funame: j.Funame,
+		line:   j.Line,
+		etype:  j.Etype,
+	}, nil
+}
+
+// parseLogText -----------------------------------------------------------------------------
+// разбор строки вида: "2006-01-02 15:04:05.000 p<pid> Inf <go-файл> <строка> <функция> <текст>"
+// - поля до текста разделяются пробелами (ширина полей "плывет" на длинных значениях и кириллице),
+// - текст события - весь остаток строки, в нем могут быть любые пробелы
+func parseLogText(line string) (Vle, error) {
+	var vle Vle
+	var tok [7]string // дата, время, pid, тип, go-файл, строка, функция
+
+	rest := line
+	for i := range tok {
+		rest = strings.TrimLeft(rest, " ")
+		j := strings.IndexByte(rest, ' ')
+		if j < 0 {
+			if i < len(tok)-1 || rest == "" {
+				return vle, errors.New("Log line: too few fields")
+			}
+			tok[i], rest = rest, ""
+			break
+		}
+		tok[i], rest = rest[:j], rest[j+1:]
+	}
+
+	// имя функции дополняется пробелами до 15 символов: убираем ровно это дополнение,
+	// чтобы не съесть пробелы в начале текста события
+	for pad := 15 - len(tok[6]); pad > 0 && strings.HasPrefix(rest, " "); pad-- {
+		rest = rest[1:]
+	}
+
+	var err error
+	vle.etime, err = time.ParseInLocation("2006-01-02 15:04:05.000", tok[0]+" "+tok[1], time.Local)
+	if err != nil {
+		return vle, err
+	}
+
+	if !strings.HasPrefix(tok[2], "p") {
+		return vle, errors.New("Log line: bad pid field: " + tok[2])
+	}
+	if vle.pid, err = strconv.ParseUint(tok[2][1:], 10, 64); err != nil {
+		return vle, err
+	}
+
+	if vle.etype, err = Etcode(tok[3]); err != nil {
+		return vle, err
+	}
+
+	vle.gfile = tok[4]
+	if vle.line, err = strconv.Atoi(tok[5]); err != nil {
+		return vle, err
+	}
+	vle.funame = tok[6]
+	vle.estr = rest
+
+	return vle, nil
+}
+
+// Etcode -----------------------------------------------------------------------------
+// тип события по его краткому имени в логе: обратное к Etname
+func Etcode(name string) (int, error) {
+	for _, etype := range []int{Einf, Eerr, Ewrn, Edbg, Eftl} {
+		if Etname(etype) == name {
+			return etype, nil
+		}
+	}
+	if strings.HasPrefix(name, "E") {
+		if etype, err := strconv.Atoi(name[1:]); err == nil {
+			return etype, nil
+		}
+	}
+	return 0, errors.New("Log line: unknown event type: " + name)
+}
+
+// ScanError строки лога, которые не удалось разобрать (ScanLog пропускает их и читает дальше)
+type ScanError struct {
+	Lines int   // число пропущенных строк
+	Line  int   // номер первой пропущенной строки
+	Err   error // ошибка разбора первой пропущенной строки
+}
+
+func (e *ScanError) Error() string {
+	return fmt.Sprintf("Log: %d bad lines skipped, first at line %d: %v", e.Lines, e.Line, e.Err)
+}
+func (e *ScanError) Unwrap() error { return e.Err }
+
+// ScanLog -----------------------------------------------------------------------------
+// построчное чтение лога из r с вызовом fn для каждого события
+// - пустые строки пропускаются
+// - неразобранная строка текстового формата - продолжение текста предыдущего события (добавляется через "\n")
+// - прочие неразобранные строки пропускаются; в конце возвращается *ScanError с их числом
+// - ошибка fn прекращает чтение
+func ScanLog(r io.Reader, fn func(vle Vle) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024) // длинные сообщения в логе не редкость
+
+	var bad *ScanError
+	var prev Vle
+	var text, ok bool // prev - событие текстового формата, есть prev
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		isjson := strings.HasPrefix(strings.TrimLeft(line, " \t"), "{")
+		vle, err := ParseLog(line)
+		switch {
+		case err != nil && !isjson && ok && text:
+			prev.estr += "\n" + line
+			continue
+		case err != nil:
+			if bad == nil {
+				bad = &ScanError{Line: n, Err: err}
+			}
+			bad.Lines++
+			continue
+		}
+
+		// событие отдаем, когда началось следующее: к нему еще могли относиться строки продолжения
+		if ok {
+			if err = fn(prev); err != nil {
+				return err
+			}
+		}
+		prev, text, ok = vle, !isjson, true
+	}
+	if ok {
+		if err := fn(prev); err != nil {
+			return err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if bad != nil {
+		return bad
+	}
+	return nil
+}
+
+// ReadLog -----------------------------------------------------------------------------
+// чтение всего лога из r в массив событий
+// - при пропущенных строках (*ScanError) возвращаются и разобранные события, и ошибка
+func ReadLog(r io.Reader) ([]Vle, error) {
+	var vles []Vle
+	err := ScanLog(r, func(vle Vle) error {
+		vles = append(vles, vle)
+		return nil
+	})
+	return vles, err
+}
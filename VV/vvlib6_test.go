@@ -0,0 +1,95 @@
+package vv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLogRoundTrip(t *testing.T) {
+	vle := Vle{pid: 42, event: 7, pname: "import", prc: 50, estr: "  text  with spaces", gfile: "main.go",
+		funame: "main.main", line: 12, etype: Ewrn, etime: time.Date(2026, 10, 17, 15, 4, 5, 123e6, time.Local)}
+
+	for _, format := range []string{"text", "json"} {
+		got, err := ParseLog(vle.format(format))
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if got.pid != vle.pid || got.etype != vle.etype || got.estr != vle.estr || got.line != vle.line ||
+			got.funame != vle.funame || got.gfile != vle.gfile || !got.etime.Equal(vle.etime) {
+			t.Errorf("%s: got %+v, want %+v", format, got, vle)
+		}
+		if format == "json" && (got.event != vle.event || got.pname != vle.pname || got.prc != vle.prc) {
+			t.Errorf("json: event fields lost: %+v", got)
+		}
+	}
+}
+
+func TestParseLogErrors(t *testing.T) {
+	for _, line := range []string{
+		"",
+		"2026-10-17 15:04:05.000 p1 Inf",
+		"2026-10-17 15:04:05.000 x1 Inf main.go 1 main.main text",
+		"2026-10-17 15:04:05.000 p1 Zzz main.go 1 main.main text",
+		"2026-10-17 15:04:05.000 p1 Inf main.go xx main.main text",
+		"17.10.2026 15:04:05.000 p1 Inf main.go 1 main.main text",
+		`{"pid": "x"}`,
+	} {
+		if _, err := ParseLog(line); err == nil {
+			t.Errorf("ParseLog(%q): want error", line)
+		}
+	}
+}
+
+func TestScanLog(t *testing.T) {
+	ev := func(pid uint64, estr string) string {
+		vle := testVle(estr)
+		vle.pid = pid
+		return vle.text()
+	}
+	in := "garbage before the first event\n" +
+		ev(1, "first line") +
+		"  second line\n" +
+		"third line\n" +
+		"\n" +
+		`{"pid": 2, "etype": 1, "estr": "json event"}` + "\n" +
+		"{broken json\n" +
+		ev(3, "last")
+
+	vles, err := ReadLog(strings.NewReader(in))
+
+	var se *ScanError
+	if !errors.As(err, &se) {
+		t.Fatalf("err = %v, want *ScanError", err)
+	}
+	if se.Lines != 2 || se.Line != 1 {
+		t.Errorf("ScanError = %d lines from %d, want 2 from 1", se.Lines, se.Line)
+	}
+
+	want := []string{"first line\n  second line\nthird line", "json event", "last"}
+	if len(vles) != len(want) {
+		t.Fatalf("got %d events, want %d", len(vles), len(want))
+	}
+	for i, vle := range vles {
+		if vle.Estr() != want[i] || vle.Pid() != uint64(i+1) {
+			t.Errorf("event %d: pid %d %q, want pid %d %q", i, vle.Pid(), vle.Estr(), i+1, want[i])
+		}
+	}
+}
+
+func TestScanLogStop(t *testing.T) {
+	in := testVle("a").text() + testVle("b").text() + testVle("c").text()
+	stop := errors.New("stop")
+
+	n := 0
+	err := ScanLog(strings.NewReader(in), func(vle Vle) error {
+		if n++; n == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || n != 2 {
+		t.Errorf("ScanLog: err %v after %d events, want stop after 2", err, n)
+	}
+}
@@ -0,0 +1,166 @@
+package vv
+
+// Ход выполнения длительных обработок (бэкапы, импорт и т.п.):
+// - воркер регистрирует процедуру под своим id обработки: Vlogger.Vproc(pid, "Backup")
+// - по ходу работы сообщает % выполнения и код события: Vlogger.Vprog(pid, 40, 2, "Таблица USERS")
+// - по окончании снимает процедуру с учета: Vlogger.Vend(pid, "Backup done")
+// Каждый вызов пишет событие в лог с заполненными pname, prc и event, а последнее состояние
+// хранится в памяти: его можно получить через Progress/Progresses или по http через ProgressHandler.
+// Пока процедура выполняется, обычные Vlog с тем же pid тоже получают pname и prc.
+// Завершенная процедура хранится еще VprKeep с Done = true: сразу после Vend её итог можно запросить по pid.
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Vpr ход выполнения процедуры
+type Vpr struct {
+	Pid   uint64    `json:"pid"`   // id обработки
+	Pname string    `json:"pname"` // имя процедуры
+	Prc   int       `json:"prc"`   // % выполнения
+	Event int       `json:"event"` // код последнего события
+	Estr  string    `json:"estr"`  // текст последнего события
+	Start time.Time `json:"start"` // время регистрации процедуры
+	Time  time.Time `json:"time"`  // время последнего отчета
+	Done  bool      `json:"done"`  // процедура завершена (Vend)
+}
+
+// VprKeep сколько хранится состояние завершенной процедуры для Progress и ProgressHandler с ?pid=N
+var VprKeep = time.Minute
+
+var vprogs struct { // реестр хода выполнения: pid -> состояние
+	sync.RWMutex
+	m map[uint64]Vpr
+}
+
+// Vproc -----------------------------------------------------------------------------
+// регистрация процедуры pname под id обработки pid
+func (vl *Vlg) Vproc(pid uint64, pname string) {
+	now := time.Now()
+	vprogs.Lock()
+	if vprogs.m == nil {
+		vprogs.m = make(map[uint64]Vpr)
+	}
+	vprPurge(now)
+	vprogs.m[pid] = Vpr{Pid: pid, Pname: pname, Start: now, Time: now}
+	vprogs.Unlock()
+
+	vl.vlog(2, pid, pname+": start", Einf, 0)
+}
+
+// Vprog -----------------------------------------------------------------------------
+// отчет о ходе выполнения процедуры pid
+// - prc   - % выполнения (0..100)
+// - event - код события, смысл задает сама процедура
+// - estr  - пояснительный текст
+func (vl *Vlg) Vprog(pid uint64, prc int, event int, estr string) {
+	if prc < 0 {
+		prc = 0
+	} else if prc > 100 {
+		prc = 100
+	}
+
+	vprogs.Lock()
+	pr, ok := vprogs.m[pid]
+	if ok && !pr.Done {
+		pr.Prc, pr.Event, pr.Estr, pr.Time = prc, event, estr, time.Now()
+		vprogs.m[pid] = pr
+	}
+	vprogs.Unlock()
+
+	vl.vlog(2, pid, estr, Einf, event)
+}
+
+// Vend -----------------------------------------------------------------------------
+// завершение процедуры pid: последнее событие с 100%
+// - процедура снимается с учета выполняющихся, но её итог доступен через Progress еще VprKeep
+func (vl *Vlg) Vend(pid uint64, estr string) {
+	vprogs.Lock()
+	if pr, ok := vprogs.m[pid]; ok && !pr.Done {
+		pr.Prc, pr.Estr, pr.Time = 100, estr, time.Now()
+		vprogs.m[pid] = pr
+	}
+	vprogs.Unlock()
+
+	vl.vlog(2, pid, estr, Einf, 0)
+
+	now := time.Now()
+	vprogs.Lock()
+	if pr, ok := vprogs.m[pid]; ok {
+		pr.Done, pr.Time = true, now
+		vprogs.m[pid] = pr
+	}
+	vprPurge(now)
+	vprogs.Unlock()
+}
+
+// vprPurge -----------------------------------------------------------------------------
+// удаление из реестра процедур, завершенных раньше чем VprKeep назад; вызывается под vprogs.Lock
+func vprPurge(now time.Time) {
+	for pid, pr := range vprogs.m {
+		if pr.Done && now.Sub(pr.Time) >= VprKeep {
+			delete(vprogs.m, pid)
+		}
+	}
+}
+
+// Progress -----------------------------------------------------------------------------
+// последнее состояние процедуры pid: выполняющейся или завершенной не раньше чем VprKeep назад (Done = true)
+func Progress(pid uint64) (Vpr, bool) {
+	vprogs.RLock()
+	pr, ok := vprogs.m[pid]
+	vprogs.RUnlock()
+	if ok && pr.Done && time.Since(pr.Time) >= VprKeep {
+		return Vpr{}, false
+	}
+	return pr, ok
+}
+
+// Progresses -----------------------------------------------------------------------------
+// состояния всех выполняющихся процедур, по возрастанию pid
+func Progresses() []Vpr {
+	vprogs.RLock()
+	prs := make([]Vpr, 0, len(vprogs.m))
+	for _, pr := range vprogs.m {
+		if !pr.Done {
+			prs = append(prs, pr)
+		}
+	}
+	vprogs.RUnlock()
+
+	sort.Slice(prs, func(i, j int) bool { return prs[i].Pid < prs[j].Pid })
+	return prs
+}
+
+// ProgressHandler -----------------------------------------------------------------------------
+// web server: ход выполнения процедур в JSON
+// - без параметров: массив всех выполняющихся процедур
+// - ?pid=N: одна процедура, выполняющаяся или недавно завершенная (см. Progress), 404 если такой нет
+func ProgressHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v interface{} = Progresses()
+
+		if s := r.URL.Query().Get("pid"); s != "" {
+			pid, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				http.Error(w, "bad pid: "+s, http.StatusBadRequest)
+				return
+			}
+			pr, ok := Progress(pid)
+			if !ok {
+				http.Error(w, "no such process: "+s, http.StatusNotFound)
+				return
+			}
+			v = pr
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(v)
+	})
+}
@@ -0,0 +1,139 @@
+package vv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testProgress ответ ProgressHandler на запрос query: код и тело
+func testProgress(t *testing.T, query string) (int, []byte) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	ProgressHandler().ServeHTTP(w, httptest.NewRequest("GET", "/progress"+query, nil))
+	return w.Code, w.Body.Bytes()
+}
+
+func TestProgress(t *testing.T) {
+	rt := NewVrt()
+	ring := NewRingSink(100)
+	rt.Log.AddSink("ring", ring, Ldbg)
+	if err := rt.Start(Vcfg{Quiet: true}); err != nil {
+		t.Fatal(err)
+	}
+	pid := Getpid()
+	spid := strconv.FormatUint(pid, 10)
+
+	rt.Log.Vproc(pid, "Backup")
+	rt.Log.Vprog(pid, 140, 2, "table USERS") // % ограничивается 100
+	rt.Log.Vprog(pid, 40, 2, "table USERS")
+	rt.Log.Vlog(pid, "plain event", Einf)
+
+	// выполняющаяся процедура: в списке и по pid
+	pr, ok := Progress(pid)
+	if !ok || pr.Pname != "Backup" || pr.Prc != 40 || pr.Event != 2 || pr.Estr != "table USERS" || pr.Done {
+		t.Errorf("Progress while running = %+v, %v", pr, ok)
+	}
+	code, body := testProgress(t, "")
+	var prs []Vpr
+	if err := json.Unmarshal(body, &prs); code != 200 || err != nil {
+		t.Fatalf("list: %d %s", code, body)
+	}
+	found := false
+	for _, p := range prs {
+		found = found || p.Pid == pid
+	}
+	if !found {
+		t.Errorf("list %s has no running pid %d", body, pid)
+	}
+
+	rt.Log.Vend(pid, "Backup done")
+	rt.Log.Vlog(pid, "after end", Einf)
+
+	// завершенная процедура: не в списке, но по pid еще отдается с Done
+	if pr, ok := Progress(pid); !ok || !pr.Done || pr.Prc != 100 || pr.Estr != "Backup done" {
+		t.Errorf("Progress after Vend = %+v, %v, want the completed state", pr, ok)
+	}
+	for _, p := range Progresses() {
+		if p.Pid == pid {
+			t.Error("completed procedure is listed as running")
+		}
+	}
+	code, body = testProgress(t, "?pid="+spid)
+	if err := json.Unmarshal(body, &pr); code != 200 || err != nil || !pr.Done {
+		t.Errorf("?pid after Vend: %d %s", code, body)
+	}
+
+	if err := rt.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]struct {
+		pname string
+		prc   int
+	}{
+		"Backup: start": {"Backup", 0},
+		"table USERS":   {"Backup", 100}, // первый отчет: 140% -> 100%
+		"plain event":   {"Backup", 40},
+		"Backup done":   {"Backup", 100},
+		"after end":     {"", 0}, // процедура завершена: обычный Vlog её не видит
+	}
+	for _, vle := range ring.Events() {
+		if w, ok := want[vle.Estr()]; ok && vle.Pid() == pid {
+			if vle.Pname() != w.pname || vle.Prc() != w.prc {
+				t.Errorf("event %q: pname %q prc %d, want %q %d", vle.Estr(), vle.Pname(), vle.Prc(), w.pname, w.prc)
+			}
+			delete(want, vle.Estr())
+		}
+	}
+	if len(want) != 0 {
+		t.Errorf("events not logged: %v", want)
+	}
+}
+
+func TestProgressKeep(t *testing.T) {
+	keep := VprKeep
+	VprKeep = 20 * time.Millisecond
+	t.Cleanup(func() { VprKeep = keep })
+
+	vl := &Vlg{}
+	pid := Getpid()
+	vl.Vproc(pid, "Import")
+	vl.Vend(pid, "Import done")
+	if _, ok := Progress(pid); !ok {
+		t.Fatal("completed procedure is not found right after Vend")
+	}
+
+	time.Sleep(2 * VprKeep)
+	if _, ok := Progress(pid); ok {
+		t.Error("completed procedure is still found after VprKeep")
+	}
+	if code, _ := testProgress(t, "?pid="+strconv.FormatUint(pid, 10)); code != 404 {
+		t.Errorf("?pid after VprKeep: %d, want 404", code)
+	}
+
+	vl.Vproc(Getpid(), "Next") // регистрация чистит реестр от устаревших процедур
+	vprogs.RLock()
+	_, kept := vprogs.m[pid]
+	vprogs.RUnlock()
+	if kept {
+		t.Error("expired procedure is not purged")
+	}
+}
+
+func TestProgressHandlerErrors(t *testing.T) {
+	for _, c := range []struct {
+		query string
+		code  int
+	}{
+		{"?pid=abc", 400},
+		{"?pid=-1", 400},
+		{"?pid=18446744073709551615", 404},
+	} {
+		if code, body := testProgress(t, c.query); code != c.code {
+			t.Errorf("%s: %d %s, want %d", c.query, code, body, c.code)
+		}
+	}
+}
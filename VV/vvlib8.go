@@ -0,0 +1,198 @@
+package vv
+
+// Среда выполнения vv:
+// - фоновые воркеры библиотеки (логгер, планировщик, менеджер памяти) принадлежат среде выполнения Vrt
+//   и живут между вызовами Start и Shutdown
+// - импорт пакета ничего не запускает и файлов не создает
+// - Vrun - среда по умолчанию: её лог - глобальный Vlogger, её канал лога - глобальный VcLog
+// - до Start лог среды пишет события прямо в стандартный поток ошибок, после Shutdown - отбрасывает
+// - Shutdown дописывает события из канала лога, закрывает файл и приемники, останавливает воркеры
+// - воркеры среды работают под супервизором: упавший логгер перезапускается, а не молча пропадает
+// - периодические задания среды (менеджер памяти и задания приложения) работают в её планировщике Scheduler()
+//
+// Пример:
+//   vv.Vlogger.File = "app.log"
+//   vv.Vrun.Start(vv.Vcfg{FreeMemory: true})
+//   defer vv.Vrun.Shutdown(context.Background())
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Vcfg параметры запуска среды выполнения
+type Vcfg struct {
+	QueueSize    int     // размер канала лога (0 - 1000)
+	Backpressure int     // поведение при заполненном канале лога: BpBlock, BpDropNew, BpDropOld, BpSpill (см. vvlib9.go)
+	SpillDir     string  // каталог файла сброса для BpSpill ("" - системный временный каталог)
+	Pidgen       *Vidgen // генератор id обработки для Getpid (nil - не менять), см. vvlib11.go
+	FreeMemory   bool    // запускать менеджер памяти с параметрами по умолчанию (см. vvlib15.go)
+	Memory       *Vmcfg  // запускать менеджер памяти с этими параметрами (nil - по FreeMemory)
+	Quiet        bool    // не выводить сообщения о старте на консоль
+}
+
+// Vrt среда выполнения vv
+type Vrt struct {
+	Log *Vlg // лог среды выполнения
+
+	mu    sync.Mutex
+	state int   // состояние: 0 - не запускалась, 1 - работает, 2 - остановлена
+	sup   Vsup  // супервизор воркеров среды (см. vvlib12.go)
+	sch   Vsch  // планировщик периодических заданий среды (см. vvlib14.go)
+	mem   *Vmem // менеджер памяти среды (nil - не запущен)
+}
+
+// Vrun среда выполнения по умолчанию
+var Vrun = &Vrt{Log: &Vlogger}
+
+// состояния среды выполнения
+const (
+	rtNew     = 0 // не запускалась
+	rtRunning = 1 // работает
+	rtStopped = 2 // остановлена
+)
+
+// NewVrt -----------------------------------------------------------------------------
+// новая среда выполнения со своим логом (например, для тестов или отдельной подсистемы)
+// - уровень лога Linf, файла нет: задайте Log.File или подключите приемники до Start
+func NewVrt() *Vrt {
+	return &Vrt{Log: &Vlg{Level: Linf}}
+}
+
+// Start -----------------------------------------------------------------------------
+// запуск среды выполнения: канал лога, логгер, планировщик и (по cfg) менеджер памяти
+func (rt *Vrt) Start(cfg Vcfg) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.start(cfg)
+}
+
+// start -----------------------------------------------------------------------------
+// Start под уже захваченным rt.mu
+func (rt *Vrt) start(cfg Vcfg) error {
+	if rt.state == rtRunning {
+		return errors.New("vv runtime is already started")
+	}
+
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+
+	// воркеры прежнего запуска еще работают, если Shutdown вернулся по ctx раньше них:
+	// новый канал лога, который никто не читает, заблокировал бы Vlog, поэтому такой запуск - ошибка
+	for _, name := range []string{"vv.logger", "vv.scheduler"} {
+		if rt.sup.running(name) {
+			return errors.New("vv runtime is still stopping: worker " + name + " is running")
+		}
+	}
+
+	vl := rt.Log
+	SetPidgen(cfg.Pidgen)
+	if rt == Vrun {
+		vpidfeed.Do(startPidfeed) // для прежнего кода, читающего id из VcPid
+	}
+
+	vc := make(chan Vle, cfg.QueueSize) // буферизованный канал для логирования событий приложения
+	rt.sup.Log, rt.sch.Log = vl, vl
+	vl.mu.Lock()
+	vl.bp, vl.spill = cfg.Backpressure, nil
+	if cfg.Backpressure == BpSpill {
+		vl.spill = &vlspill{dir: cfg.SpillDir}
+	}
+	vl.mu.Unlock()
+
+	// стартуем воркер логгера: после паники он перезапускается, события в канале при этом не теряются
+	if _, err := rt.sup.Go("vv.logger", Vrsp{Mode: RsBackoff, Min: 100 * time.Millisecond, Max: 10 * time.Second},
+		func(ctx context.Context, pid uint64) error {
+			return logger(ctx, vl, vc, cfg.Quiet)
+		}); err != nil {
+		return err
+	}
+
+	// стартуем воркер планировщика периодических заданий
+	if _, err := rt.sup.Go("vv.scheduler", Vrsp{Mode: RsAlways}, func(ctx context.Context, pid uint64) error {
+		return rt.sch.Run(ctx)
+	}); err != nil {
+		rt.sup.Stop("vv.logger")
+		return err
+	}
+
+	// канал ставится в лог, только когда его читает логгер
+	rt.state = rtRunning
+	vl.mu.Lock()
+	vl.vc, vl.done = vc, false
+	vl.mu.Unlock()
+	if vl == &Vlogger {
+		VcLog = vc
+	}
+
+	rt.mem = nil
+	if cfg.Memory != nil || cfg.FreeMemory { // менеджер памяти - одно из заданий планировщика
+		var mc Vmcfg
+		if cfg.Memory != nil {
+			mc = *cfg.Memory
+		}
+		rt.mem = NewVmem(mc)
+		rt.mem.Log = vl
+		rt.sch.Every("vv.memory", rt.mem.Cfg.Interval, 0, rt.mem.Check)
+	} else {
+		rt.sch.Del("vv.memory")
+	}
+
+	vl.Vlog(0, "Application start", Einf) // первая запись логгера при запуске приложения, id тупо ставим = 0
+	if !cfg.Quiet {
+		fmt.Print("Initialization completed\n") // выводим на консоль инфо-сообщение об успешном завершении инициализации
+	}
+	return nil
+}
+
+// Shutdown -----------------------------------------------------------------------------
+// остановка среды выполнения
+// - новые события лога больше не принимаются, уже поставленные в канал дописываются в приемники
+// - файл лога и приемники сбрасываются и закрываются, воркеры останавливаются
+// - если ctx завершится раньше, чем воркеры, возвращается ошибка ctx; воркеры доработают сами
+func (rt *Vrt) Shutdown(ctx context.Context) error {
+	rt.mu.Lock()
+	if rt.state != rtRunning {
+		rt.state = rtStopped
+		rt.mu.Unlock()
+		return nil
+	}
+	rt.state = rtStopped
+
+	rt.Log.Vlog(0, "Application stop", Einf)
+
+	rt.Log.cmu.Lock() // ждем отправок, уже начатых в Vlog: воркер логгера еще работает и их дочитает
+	rt.Log.mu.Lock()
+	rt.Log.vc, rt.Log.done = nil, true // дальнейшие Vlog отбрасываются
+	rt.Log.mu.Unlock()
+	rt.Log.cmu.Unlock()
+
+	rt.mu.Unlock()
+
+	return rt.sup.StopAll(ctx)
+}
+
+// Workers -----------------------------------------------------------------------------
+// воркеры среды выполнения и их состояние
+func (rt *Vrt) Workers() []Vwrk {
+	return rt.sup.Workers()
+}
+
+// Memory -----------------------------------------------------------------------------
+// менеджер памяти среды выполнения (nil, если среда запущена без него)
+func (rt *Vrt) Memory() *Vmem {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.mem
+}
+
+// Scheduler -----------------------------------------------------------------------------
+// планировщик периодических заданий среды выполнения
+// - задания можно добавлять и до Start: они начнут выполняться после запуска среды
+func (rt *Vrt) Scheduler() *Vsch {
+	return &rt.sch
+}
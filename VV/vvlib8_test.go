@@ -0,0 +1,187 @@
+package vv
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestVrtStartShutdown(t *testing.T) {
+	rt := NewVrt()
+	ring := NewRingSink(1000)
+	rt.Log.AddSink("ring", ring, Ldbg)
+
+	if err := rt.Start(Vcfg{Quiet: true, QueueSize: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Start(Vcfg{Quiet: true}); err == nil {
+		t.Error("second Start: want error")
+	}
+
+	for i := 0; i < 100; i++ { // больше размера канала: Shutdown должен дописать все
+		rt.Log.Vlog(1, "event", Einf)
+	}
+	if err := rt.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	for _, vle := range ring.Events() {
+		if vle.Estr() == "event" {
+			n++
+		}
+	}
+	if n != 100 {
+		t.Errorf("events written before Shutdown returned: %d, want 100", n)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		rt.Log.Vlog(1, "after shutdown", Einf)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Vlog after Shutdown blocked")
+	}
+	if evs := ring.Events(); evs[len(evs)-1].Estr() == "after shutdown" {
+		t.Error("event after Shutdown was written")
+	}
+}
+
+func TestVrtShutdownConcurrentLog(t *testing.T) {
+	rt := NewVrt()
+	rt.Log.AddSink("ring", NewRingSink(10), Ldbg)
+	if err := rt.Start(Vcfg{Quiet: true, QueueSize: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				rt.Log.Vlog(1, "event", Einf)
+			}
+		}()
+	}
+
+	time.Sleep(time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := rt.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Vlog blocked after Shutdown with a full queue")
+	}
+}
+
+func TestVrtBeforeStart(t *testing.T) {
+	stderr := testStderr(t)
+	dir := t.TempDir()
+	rt := NewVrt()
+	rt.Log.File = filepath.Join(dir, "app.log")
+	ring := NewRingSink(10)
+	rt.Log.AddSink("ring", ring, Ldbg)
+
+	rt.Log.Vlog(1, "before start", Einf)
+	rt.Log.Vlog(1, "filtered before start", Edbg) // уровень основного лога Linf
+	if _, err := os.Stat(rt.Log.File); !os.IsNotExist(err) {
+		t.Errorf("log file exists before Start: %v", err)
+	}
+	if len(ring.Events()) != 0 {
+		t.Error("sink got an event before Start")
+	}
+
+	if err := rt.Start(Vcfg{Quiet: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	rt.Log.Vlog(1, "after shutdown", Eerr)
+
+	b, _ := os.ReadFile(stderr)
+	if !strings.Contains(string(b), "before start") {
+		t.Errorf("event before Start is not in stderr: %q", b)
+	}
+	for _, s := range []string{"filtered before start", "after shutdown"} {
+		if strings.Contains(string(b), s) {
+			t.Errorf("%q written to stderr", s)
+		}
+	}
+}
+
+// testBlockSink приемник лога, Close которого ждет release
+type testBlockSink struct {
+	release chan struct{}
+}
+
+func (bs *testBlockSink) Write(Vle) error { return nil }
+func (bs *testBlockSink) Flush() error    { return nil }
+func (bs *testBlockSink) Close() error {
+	<-bs.release
+	return nil
+}
+
+func TestVrtRestartAfterShutdownTimeout(t *testing.T) {
+	rt := NewVrt()
+	bs := &testBlockSink{release: make(chan struct{})}
+	rt.Log.AddSink("block", bs, Ldbg)
+	if err := rt.Start(Vcfg{Quiet: true, QueueSize: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// логгер завис на закрытии приемника: Shutdown возвращается по ctx, а логгер еще работает
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := rt.Shutdown(ctx); err == nil {
+		t.Fatal("Shutdown with a hung logger: want ctx error")
+	}
+	if err := rt.Start(Vcfg{Quiet: true, QueueSize: 1}); err == nil {
+		t.Fatal("Start while the previous logger is running: want error")
+	}
+
+	// лог остановлен, а не переустановлен: Vlog не блокируется на канале, который никто не читает
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			rt.Log.Vlog(1, "event", Einf)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Vlog blocked after a failed Start")
+	}
+
+	close(bs.release)
+	for end := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		err := rt.Start(Vcfg{Quiet: true})
+		if err == nil {
+			break
+		}
+		if time.Now().After(end) {
+			t.Fatalf("Start after the previous logger stopped: %v", err)
+		}
+	}
+	if err := rt.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
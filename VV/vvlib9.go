@@ -0,0 +1,173 @@
+package vv
+
+// Поведение Vlog при заполненном канале лога (backpressure), задается Vcfg.Backpressure:
+// - BpBlock   - ждать места в канале (прежнее поведение): медленный приемник тормозит всех, кто пишет в лог
+// - BpDropNew - отбросить новое событие
+// - BpDropOld - отбросить самое старое событие из канала и поставить новое
+// - BpSpill   - сбросить событие во временный файл (JSON Lines); логгер дочитает его, когда канал разгрузится.
+//   Порядок событий при этом не сохраняется: сброшенные события попадают в приемники позже тех, что ждали в канале.
+// Счетчики отброшенных и сброшенных в файл событий доступны через Vlg.Stats.
+// При отбрасывании событий логгер раз в секунду пишет в приемники предупреждение с числом потерянных событий.
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// режимы поведения при заполненном канале лога
+const (
+	BpBlock   = 0 // ждать места в канале
+	BpDropNew = 1 // отбросить новое событие
+	BpDropOld = 2 // отбросить самое старое событие
+	BpSpill   = 3 // сбросить событие во временный файл
+)
+
+// Vlst состояние очереди лога
+type Vlst struct {
+	Queue   int    // событий в канале лога
+	Cap     int    // емкость канала лога
+	Dropped uint64 // отброшено событий с момента старта
+	Spilled uint64 // сброшено во временный файл с момента старта
+	Pending int    // ждет в файле сброса
+}
+
+// vlspill временный файл для событий, не поместившихся в канал лога
+type vlspill struct {
+	mu  sync.Mutex
+	dir string   // каталог временных файлов ("" - системный)
+	f   *os.File // текущий файл сброса (nil - сброшенных событий нет)
+	n   int      // событий в текущем файле
+}
+
+// vlcnt счетчики очереди лога
+type vlcnt struct {
+	dropped  atomic.Uint64 // отброшено событий
+	spilled  atomic.Uint64 // сброшено во временный файл
+	reported uint64        // отброшено на момент последнего предупреждения (только воркер логгера)
+}
+
+// send -----------------------------------------------------------------------------
+// постановка события в канал лога по режиму bp
+func (vl *Vlg) send(vc chan Vle, bp int, sp *vlspill, vle Vle) {
+	switch bp {
+	case BpDropNew:
+		select {
+		case vc <- vle:
+		default:
+			vl.cnt.dropped.Add(1)
+		}
+
+	case BpDropOld:
+		for {
+			select {
+			case vc <- vle:
+				return
+			default:
+			}
+			select {
+			case <-vc:
+				vl.cnt.dropped.Add(1)
+			default:
+			}
+		}
+
+	case BpSpill:
+		select {
+		case vc <- vle:
+		default:
+			if err := sp.put(vle); err != nil {
+				vl.cnt.dropped.Add(1)
+				log.Println("Log spill: write error:", err)
+			} else {
+				vl.cnt.spilled.Add(1)
+			}
+		}
+
+	default:
+		vc <- vle
+	}
+}
+
+// Stats -----------------------------------------------------------------------------
+// состояние очереди лога
+func (vl *Vlg) Stats() Vlst {
+	vl.mu.RLock()
+	vc, sp := vl.vc, vl.spill
+	vl.mu.RUnlock()
+
+	st := Vlst{Queue: len(vc), Cap: cap(vc), Dropped: vl.cnt.dropped.Load(), Spilled: vl.cnt.spilled.Load()}
+	if sp != nil {
+		sp.mu.Lock()
+		st.Pending = sp.n
+		sp.mu.Unlock()
+	}
+	return st
+}
+
+// overflow -----------------------------------------------------------------------------
+// предупреждение в приемники об отброшенных с прошлого раза событиях: вызывается воркером логгера
+func (vl *Vlg) overflow() {
+	dropped := vl.cnt.dropped.Load()
+	if dropped == vl.cnt.reported {
+		return
+	}
+	n := dropped - vl.cnt.reported
+	vl.cnt.reported = dropped
+
+	estr := "Log queue overflow: " + strconv.FormatUint(n, 10) + " events dropped"
+	log.Println(estr)
+	vl.write(Vle{etime: time.Now(), etype: Ewrn, estr: estr, funame: "vv.logger"})
+}
+
+// put -----------------------------------------------------------------------------
+// запись события в файл сброса
+func (sp *vlspill) put(vle Vle) error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if sp.f == nil {
+		f, err := os.CreateTemp(sp.dir, "vvlog-spill-*.jsonl")
+		if err != nil {
+			return err
+		}
+		sp.f = f
+	}
+
+	if _, err := sp.f.WriteString(vle.json()); err != nil {
+		return err
+	}
+	sp.n++
+	return nil
+}
+
+// replay -----------------------------------------------------------------------------
+// дочитывание сброшенных событий в приемники: вызывается воркером логгера
+// - файл забирается целиком, новые сброшенные события пойдут в новый файл
+func (sp *vlspill) replay(vl *Vlg) {
+	sp.mu.Lock()
+	f := sp.f
+	sp.f, sp.n = nil, 0
+	sp.mu.Unlock()
+
+	if f == nil {
+		return
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := f.Seek(0, 0); err != nil {
+		log.Println("Log spill: read error:", err)
+		return
+	}
+	err := ScanLog(f, func(vle Vle) error {
+		vl.write(vle)
+		return nil
+	})
+	if err != nil {
+		log.Println("Log spill: read error:", err)
+	}
+}
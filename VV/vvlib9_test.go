@@ -0,0 +1,123 @@
+package vv
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// testGateSink приемник лога, который держит логгер на первой записи до release
+type testGateSink struct {
+	entered chan struct{} // логгер вошел в первую запись
+	release chan struct{} // закрыть - отпустить логгер
+	ring    *RingSink     // записанные события
+}
+
+func newTestGateSink() *testGateSink {
+	return &testGateSink{entered: make(chan struct{}, 1), release: make(chan struct{}), ring: NewRingSink(100)}
+}
+
+func (gs *testGateSink) Write(vle Vle) error {
+	select {
+	case gs.entered <- struct{}{}:
+	default:
+	}
+	<-gs.release
+	return gs.ring.Write(vle)
+}
+func (gs *testGateSink) Flush() error { return nil }
+func (gs *testGateSink) Close() error { return nil }
+
+// estrs тексты записанных событий, кроме служебных событий среды выполнения
+func (gs *testGateSink) estrs() string {
+	var s []string
+	for _, vle := range gs.ring.Events() {
+		if vle.Pid() == 1 || strings.HasPrefix(vle.Estr(), "Log queue overflow") {
+			s = append(s, vle.Estr())
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+// testBackpressure среда с каналом лога на 2 события, логгер которой держит первая запись:
+// в канал ставятся события e1..e4; возвращается среда и её приемник
+func testBackpressure(t *testing.T, bp int) (*Vrt, *testGateSink) {
+	t.Helper()
+	rt := NewVrt()
+	gs := newTestGateSink()
+	rt.Log.AddSink("gate", gs, Ldbg)
+	if err := rt.Start(Vcfg{Quiet: true, QueueSize: 2, Backpressure: bp, SpillDir: t.TempDir()}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rt.Shutdown(context.Background()) })
+	<-gs.entered // логгер держит событие "Application start", канал пуст
+
+	for _, s := range []string{"e1", "e2", "e3", "e4"} {
+		rt.Log.Vlog(1, s, Einf)
+	}
+	return rt, gs
+}
+
+func TestBackpressure(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		bp      int
+		stats   Vlst
+		written string
+	}{
+		{"BpDropNew", BpDropNew, Vlst{Queue: 2, Cap: 2, Dropped: 2}, "e1 e2 Log queue overflow: 2 events dropped"},
+		{"BpDropOld", BpDropOld, Vlst{Queue: 2, Cap: 2, Dropped: 2}, "e3 e4 Log queue overflow: 2 events dropped"},
+		{"BpSpill", BpSpill, Vlst{Queue: 2, Cap: 2, Spilled: 2, Pending: 2}, "e1 e2 e3 e4"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			rt, gs := testBackpressure(t, c.bp)
+			if st := rt.Log.Stats(); st != c.stats {
+				t.Errorf("Stats with a full queue = %+v, want %+v", st, c.stats)
+			}
+
+			close(gs.release)
+			// сброшенные события дочитываются, когда канал разгрузится, а предупреждение пишется раз в секунду
+			testEventually(t, "queue to drain", func() bool { return gs.estrs() == c.written })
+			st := rt.Log.Stats()
+			if st.Queue != 0 || st.Pending != 0 || st.Dropped != c.stats.Dropped || st.Spilled != c.stats.Spilled {
+				t.Errorf("Stats after drain = %+v", st)
+			}
+		})
+	}
+}
+
+func TestBackpressureBlock(t *testing.T) {
+	rt := NewVrt()
+	gs := newTestGateSink()
+	rt.Log.AddSink("gate", gs, Ldbg)
+	if err := rt.Start(Vcfg{Quiet: true, QueueSize: 2}); err != nil {
+		t.Fatal(err)
+	}
+	<-gs.entered
+
+	done := make(chan struct{})
+	go func() {
+		for _, s := range []string{"e1", "e2", "e3"} {
+			rt.Log.Vlog(1, s, Einf)
+		}
+		close(done)
+	}()
+	testEventually(t, "queue to fill", func() bool { return rt.Log.Stats().Queue == 2 })
+	select {
+	case <-done:
+		t.Fatal("BpBlock: Vlog returned with a full queue")
+	default:
+	}
+
+	close(gs.release)
+	<-done
+	if err := rt.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := gs.estrs(); got != "e1 e2 e3" {
+		t.Errorf("BpBlock written %q, want all events", got)
+	}
+	if st := rt.Log.Stats(); st.Dropped != 0 || st.Spilled != 0 {
+		t.Errorf("BpBlock Stats = %+v, want nothing dropped", st)
+	}
+}
@@ -0,0 +1,288 @@
+// Команда vvgen: статические файлы каталога -> программный код (vv.Generate)
+// вместо одноразового main.go в каждом проекте; удобна для go generate:
+//
+//	//go:generate go run github.com/ivanaspi88/vlib/cmd/vvgen -in static -var assets
+//
+// Флаги:
+//
+//	-in dir              каталог статических файлов (static)
+//	-o file              файл программного кода (<var>_vfsdata.go)
+//	-pkg name            пакет программного кода ($GOPACKAGE при go generate, иначе main)
+//	-var name            переменная http.FileSystem (assets)
+//	-tags expr           условия сборки (build tags)
+//	-comment text        комментарий переменной
+//	-include glob        включать только подходящие файлы (можно несколько раз и через запятую)
+//	-exclude glob        исключать подходящие файлы и каталоги (можно несколько раз и через запятую)
+//	-no-default-exclude  не исключать по умолчанию файлы и каталоги на "." и резервные копии редакторов
+//	-compress glob=mode  сжатие подходящих файлов: never, always, auto или уровень gzip 1-9 (always:6, auto:6)
+//	-min-compress n      файлы меньше n байт хранить без сжатия
+//	-max-compress n      файлы больше n байт хранить без сжатия
+//	-modtime time        время изменения всех файлов и каталогов, RFC 3339 или секунды Unix
+//	                     ($SOURCE_DATE_EPOCH, если задана, иначе время самих файлов)
+//	-zero-modtime        нулевое время изменения всех файлов и каталогов: код зависит только от содержимого
+//	-n                   только список файлов, которые попадут в программный код, и их сжатие, без записи
+//	-check               проверка: код возврата 1, если файл программного кода устарел; только вместе
+//	                     с -modtime, -zero-modtime или $SOURCE_DATE_EPOCH - время файлов у каждой копии
+//	                     репозитория свое
+//
+// Шаблон glob со "/" сравнивается с путем файла от каталога -in (path.Match, например "img/*.png"),
+// без "/" - с именем файла (например "*.map"); подробнее - vv.Options.
+// Файл -o внутри каталога -in исключается автоматически: программный код не встраивает сам себя.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	vv "github.com/ivanaspi88/vlib/VV"
+)
+
+// vglobs список шаблонов из повторяемого флага
+type vglobs []string
+
+// String -----------------------------------------------------------------------------
+func (g *vglobs) String() string { return strings.Join(*g, ",") }
+
+// Set -----------------------------------------------------------------------------
+func (g *vglobs) Set(s string) error {
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("bad pattern %q: %v", p, err)
+		}
+		*g = append(*g, p)
+	}
+	return nil
+}
+
+// vrules правила сжатия из повторяемого флага -compress
+type vrules []vv.CompressRule
+
+// String -----------------------------------------------------------------------------
+func (r *vrules) String() string {
+	s := make([]string, len(*r))
+	for i, c := range *r {
+		s[i] = c.Pattern + "=" + c.Mode.String() + ":" + strconv.Itoa(c.Level)
+	}
+	return strings.Join(s, ",")
+}
+
+// Set -----------------------------------------------------------------------------
+// glob=never, glob=always[:level], glob=auto[:level], glob=level
+func (r *vrules) Set(s string) error {
+	pattern, mode, ok := strings.Cut(s, "=")
+	if !ok || pattern == "" || mode == "" {
+		return errors.New("want pattern=mode")
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("bad pattern %q: %v", pattern, err)
+	}
+
+	rule := vv.CompressRule{Pattern: pattern}
+	name, level, _ := strings.Cut(mode, ":")
+	switch name {
+	case "never":
+		rule.Mode = vv.CompressNever
+	case "always":
+		rule.Mode = vv.CompressAlways
+	case "auto":
+	default:
+		name, level = "", name
+	}
+	if level != "" && name != "never" {
+		n, err := strconv.Atoi(level)
+		if err != nil || n < 1 || n > 9 {
+			return fmt.Errorf("bad compression %q: want never, always, auto or level 1-9", mode)
+		}
+		rule.Level = n
+	}
+	*r = append(*r, rule)
+	return nil
+}
+
+// main -----------------------------------------------------------------------------
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run -----------------------------------------------------------------------------
+// запуск команды с аргументами args; возвращает код возврата: 0 - успех, 1 - ошибка или код устарел
+// (-check), 2 - неверные аргументы
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	lg := log.New(stderr, "vvgen: ", 0)
+
+	var include, exclude vglobs
+	var compress vrules
+	fl := flag.NewFlagSet("vvgen", flag.ContinueOnError)
+	fl.SetOutput(stderr)
+	in := fl.String("in", "static", "input directory")
+	out := fl.String("o", "", "output file (default \"<var>_vfsdata.go\")")
+	pkg := fl.String("pkg", vv.Vifs(os.Getenv("GOPACKAGE") == "", "main", os.Getenv("GOPACKAGE")), "package name of the generated code")
+	name := fl.String("var", "assets", "name of the http.FileSystem variable")
+	tags := fl.String("tags", "", "build tags of the generated code")
+	comment := fl.String("comment", "", "comment of the variable (default \"<var> statically implements ...\")")
+	fl.Var(&include, "include", "embed only files matching the glob `pattern` (repeatable, comma-separated)")
+	fl.Var(&exclude, "exclude", "skip files and directories matching the glob `pattern` (repeatable, comma-separated)")
+	noDefExclude := fl.Bool("no-default-exclude", false, "don't skip dotfiles and editor backups")
+	fl.Var(&compress, "compress", "compression of files matching pattern: never, always, auto or level 1-9 (`pattern=mode`, repeatable)")
+	minCompress := fl.Int64("min-compress", 0, "store files smaller than `n` bytes raw")
+	maxCompress := fl.Int64("max-compress", 0, "store files larger than `n` bytes raw (0 - no limit)")
+	modtime := fl.String("modtime", os.Getenv("SOURCE_DATE_EPOCH"), "modification `time` of all files, RFC 3339 or Unix seconds (default $SOURCE_DATE_EPOCH)")
+	zeroModtime := fl.Bool("zero-modtime", false, "zero modification times of all files")
+	dry := fl.Bool("n", false, "dry run: list the files to embed and exit")
+	check := fl.Bool("check", false, "exit with status 1 if the output file is out of date (needs -modtime or -zero-modtime)")
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), "usage: vvgen [flags]")
+		fl.PrintDefaults()
+	}
+	if err := fl.Parse(args); err != nil {
+		return 2
+	}
+	if fl.NArg() > 0 {
+		fl.Usage()
+		return 2
+	}
+
+	mtime, err := parseTime(*modtime)
+	if err != nil {
+		lg.Printf("bad -modtime %q: want RFC 3339 time or Unix seconds", *modtime)
+		return 2
+	}
+	if *check && mtime.IsZero() && !*zeroModtime { // время файлов у каждой копии репозитория свое
+		lg.Print("-check needs stable modification times: generate and check with -modtime, -zero-modtime or $SOURCE_DATE_EPOCH")
+		return 2
+	}
+
+	if fi, err := os.Stat(*in); err != nil {
+		lg.Print(err)
+		return 1
+	} else if !fi.IsDir() {
+		lg.Printf("%s is not a directory", *in)
+		return 1
+	}
+	if *out == "" {
+		*out = strings.ToLower(*name) + "_vfsdata.go"
+	}
+
+	if rel, ok := inside(*in, *out); ok { // иначе каждый запуск встраивал бы прежнюю версию кода
+		exclude = append(exclude, "/"+globQuote(rel))
+	}
+
+	input := http.Dir(*in)
+	opt := vv.Options{Filename: *out, PackageName: *pkg, BuildTags: *tags, VariableName: *name, VariableComment: *comment,
+		Include: include, Exclude: exclude, NoDefaultExclude: *noDefExclude, Compress: compress,
+		MinCompressSize: *minCompress, MaxCompressSize: *maxCompress, ModTime: mtime, ZeroModTime: *zeroModtime}
+
+	switch {
+	case *dry:
+		err = list(input, &opt, stdout)
+	case *check:
+		var b, old []byte
+		if b, err = vv.GenerateBytes(input, opt); err != nil {
+			break
+		}
+		if old, err = os.ReadFile(*out); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if !bytes.Equal(b, old) {
+			lg.Printf("%s is out of date: run vvgen without -check", *out)
+			return 1
+		}
+		err = nil
+	default:
+		err = vv.Generate(input, opt)
+	}
+	if err != nil {
+		lg.Print(err)
+		return 1
+	}
+	return 0
+}
+
+// parseTime -----------------------------------------------------------------------------
+// время по строке RFC 3339 или секундам Unix ("" - нулевое время)
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// inside -----------------------------------------------------------------------------
+// путь файла name от каталога dir через "/", если файл лежит внутри dir
+func inside(dir, name string) (string, bool) {
+	adir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	aname, err := filepath.Abs(name)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(adir, aname)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// globQuote -----------------------------------------------------------------------------
+// экранирование спецсимволов path.Match: имя файла как шаблон, совпадающий только с ним самим
+func globQuote(s string) string {
+	var sb strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(`*?[\`, c) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+// list -----------------------------------------------------------------------------
+// список файлов, которые попадут в программный код: размер, сжатие и путь
+// - auto - сжатие, если оно уменьшает файл (см. vv.CompressAuto)
+func list(input http.FileSystem, opt *vv.Options, w io.Writer) error {
+	var n, size int64
+	err := vv.Walk(input, "/", func(name string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case !opt.Keep(name, fi.IsDir()) && fi.IsDir():
+			return filepath.SkipDir
+		case !opt.Keep(name, fi.IsDir()) || fi.IsDir():
+			return nil
+		}
+		mode, level := opt.Compression(name, fi.Size())
+		comp := mode.String()
+		if mode != vv.CompressNever {
+			comp += ":" + strconv.Itoa(level)
+		}
+		n, size = n+1, size+fi.Size()
+		_, err = fmt.Fprintf(w, "%10d %-8s %s\n", fi.Size(), comp, name)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%d files, %d bytes\n", n, size)
+	return err
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	vv "github.com/ivanaspi88/vlib/VV"
+)
+
+// testRun запуск команды с аргументами args: код возврата, stdout и stderr
+func testRun(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// testDir каталог статических файлов для команды
+func testDir(t *testing.T) string {
+	t.Helper()
+	t.Setenv("SOURCE_DATE_EPOCH", "") // умолчание -modtime
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"static/index.html":   strings.Repeat("<p>hello</p>\n", 50),
+		"static/img/logo.png": "\x89PNG raw bytes",
+		"static/.hidden":      "secret",
+	} {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestCompressFlag(t *testing.T) {
+	for _, c := range []struct {
+		arg  string
+		want vv.CompressRule // Pattern "" - ошибка
+	}{
+		{"*.png=never", vv.CompressRule{Pattern: "*.png", Mode: vv.CompressNever}},
+		{"*.png=never:5", vv.CompressRule{Pattern: "*.png", Mode: vv.CompressNever}}, // уровень без сжатия не нужен
+		{"*.js=always", vv.CompressRule{Pattern: "*.js", Mode: vv.CompressAlways}},
+		{"*.js=always:9", vv.CompressRule{Pattern: "*.js", Mode: vv.CompressAlways, Level: 9}},
+		{"*.css=auto:3", vv.CompressRule{Pattern: "*.css", Mode: vv.CompressAuto, Level: 3}},
+		{"img/*=1", vv.CompressRule{Pattern: "img/*", Mode: vv.CompressAuto, Level: 1}},
+		{"*.x", vv.CompressRule{}},
+		{"=never", vv.CompressRule{}},
+		{"*.x=", vv.CompressRule{}},
+		{"*.x=fast", vv.CompressRule{}},
+		{"*.x=always:0", vv.CompressRule{}},
+		{"*.x=10", vv.CompressRule{}},
+		{"[=never", vv.CompressRule{}},
+	} {
+		var r vrules
+		err := r.Set(c.arg)
+		switch {
+		case c.want.Pattern == "" && err == nil:
+			t.Errorf("%q: want error, got %+v", c.arg, r)
+		case c.want.Pattern != "" && (err != nil || len(r) != 1 || r[0] != c.want):
+			t.Errorf("%q: %+v, %v, want %+v", c.arg, r, err, c.want)
+		}
+	}
+}
+
+func TestGlobsFlag(t *testing.T) {
+	var g vglobs
+	if err := g.Set("*.js, ,img/*.png"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Set("*.css"); err != nil {
+		t.Fatal(err)
+	}
+	if g.String() != "*.js,img/*.png,*.css" {
+		t.Errorf("globs = %q", g.String())
+	}
+	if err := g.Set("a,["); err == nil {
+		t.Error("bad pattern: want error")
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	for _, c := range []struct {
+		s    string
+		want string // "" - нулевое время, "error" - ошибка
+	}{
+		{"", ""},
+		{"1700000000", "2023-11-14T22:13:20Z"},
+		{"2020-01-02T03:04:05+01:00", "2020-01-02T02:04:05Z"},
+		{"yesterday", "error"},
+	} {
+		tm, err := parseTime(c.s)
+		got := tm.UTC().Format("2006-01-02T15:04:05Z")
+		switch {
+		case err != nil:
+			got = "error"
+		case tm.IsZero():
+			got = ""
+		}
+		if got != c.want {
+			t.Errorf("parseTime(%q) = %q, want %q", c.s, got, c.want)
+		}
+	}
+}
+
+func TestInside(t *testing.T) {
+	dir := t.TempDir()
+	for _, c := range []struct {
+		name string
+		rel  string // "" - не внутри
+	}{
+		{filepath.Join(dir, "assets_vfsdata.go"), "assets_vfsdata.go"},
+		{filepath.Join(dir, "sub", "a.go"), "sub/a.go"},
+		{filepath.Join(dir, "sub", "..", "b.go"), "b.go"},
+		{dir, ""},
+		{filepath.Dir(dir), ""},
+		{filepath.Join(filepath.Dir(dir), "x.go"), ""},
+		{dir + "x", ""}, // соседний каталог с тем же началом имени
+		{filepath.Join(dir, "..x"), "..x"},
+	} {
+		rel, ok := inside(dir, c.name)
+		if rel != c.rel || ok != (c.rel != "") {
+			t.Errorf("inside(%q) = %q, %v, want %q", c.name, rel, ok, c.rel)
+		}
+	}
+}
+
+func TestGlobQuote(t *testing.T) {
+	for _, name := range []string{"a.go", "a*b?.go", "[x].go", `a\b.go`, "sub/[1].go"} {
+		q := globQuote(name)
+		if ok, err := filepath.Match(q, name); err != nil || !ok {
+			t.Errorf("globQuote(%q) = %q does not match itself: %v", name, q, err)
+		}
+	}
+	if ok, _ := filepath.Match(globQuote("a*.go"), "abc.go"); ok {
+		t.Error("quoted * matches other names")
+	}
+}
+
+func TestRunList(t *testing.T) {
+	dir := testDir(t)
+	code, stdout, stderr := testRun(t, "-n", "-in", filepath.Join(dir, "static"), "-compress", "*.png=never")
+	if code != 0 {
+		t.Fatalf("code %d: %s", code, stderr)
+	}
+	for _, want := range []string{
+		"       650 auto:9   /index.html", // без правила - auto с наибольшим сжатием
+		"        14 never    /img/logo.png",
+		"2 files, 664 bytes",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("no %q in\n%s", want, stdout)
+		}
+	}
+	if strings.Contains(stdout, ".hidden") {
+		t.Errorf("dotfile listed:\n%s", stdout)
+	}
+	if _, err := os.Stat("assets_vfsdata.go"); err == nil {
+		t.Error("-n wrote the output file")
+	}
+}
+
+func TestRunCheck(t *testing.T) {
+	dir := testDir(t)
+	in, out := filepath.Join(dir, "static"), filepath.Join(dir, "assets_vfsdata.go")
+
+	// без стабильного времени -check на свежей копии репозитория всегда видел бы устаревший код
+	if code, _, stderr := testRun(t, "-check", "-in", in, "-o", out); code != 2 || !strings.Contains(stderr, "-check needs stable modification times") {
+		t.Errorf("-check without -modtime: code %d, %q", code, stderr)
+	}
+
+	if code, _, stderr := testRun(t, "-check", "-zero-modtime", "-in", in, "-o", out); code != 1 || !strings.Contains(stderr, "is out of date") {
+		t.Errorf("-check without the output file: code %d, %q", code, stderr)
+	}
+	if code, _, stderr := testRun(t, "-zero-modtime", "-in", in, "-o", out); code != 0 {
+		t.Fatalf("generate: code %d, %s", code, stderr)
+	}
+	if code, _, stderr := testRun(t, "-check", "-zero-modtime", "-in", in, "-o", out); code != 0 {
+		t.Errorf("-check after generate: code %d, %s", code, stderr)
+	}
+
+	// время самих файлов не влияет на код: сгенерированное с -modtime проверяется на другой копии
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	if code, _, stderr := testRun(t, "-in", in, "-o", out); code != 0 {
+		t.Fatalf("generate with $SOURCE_DATE_EPOCH: code %d, %s", code, stderr)
+	}
+	now := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(in, "index.html"), now, now); err != nil {
+		t.Fatal(err)
+	}
+	if code, _, stderr := testRun(t, "-check", "-in", in, "-o", out); code != 0 {
+		t.Errorf("-check with $SOURCE_DATE_EPOCH after touch: code %d, %s", code, stderr)
+	}
+
+	if err := os.WriteFile(filepath.Join(in, "new.txt"), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code, _, _ := testRun(t, "-check", "-in", in, "-o", out); code != 1 {
+		t.Errorf("-check after a new file: code %d, want 1", code)
+	}
+}
+
+func TestRunArgs(t *testing.T) {
+	dir := testDir(t)
+	in := filepath.Join(dir, "static")
+	for _, c := range []struct {
+		args   []string
+		code   int
+		stderr string
+	}{
+		{[]string{"-in", in, "-compress", "*.css="}, 2, "want pattern=mode"},
+		{[]string{"-in", in, "-include", "["}, 2, "bad pattern"},
+		{[]string{"-in", in, "-modtime", "yesterday"}, 2, "bad -modtime"},
+		{[]string{"-in", in, "extra"}, 2, "usage: vvgen"},
+		{[]string{"-in", filepath.Join(in, "index.html")}, 1, "is not a directory"},
+		{[]string{"-in", filepath.Join(dir, "missing")}, 1, "no such file"},
+	} {
+		if code, _, stderr := testRun(t, c.args...); code != c.code || !strings.Contains(stderr, c.stderr) {
+			t.Errorf("%q: code %d, %q, want %d with %q", c.args, code, stderr, c.code, c.stderr)
+		}
+	}
+}
+
+func TestRunSelfExclude(t *testing.T) {
+	dir := testDir(t)
+	in := filepath.Join(dir, "static")
+	out := filepath.Join(in, "assets[1]_vfsdata.go") // файл -o внутри -in, имя со спецсимволами шаблона
+	for i := 0; i < 2; i++ {
+		if code, _, stderr := testRun(t, "-zero-modtime", "-in", in, "-o", out); code != 0 {
+			t.Fatalf("run %d: code %d, %s", i, code, stderr)
+		}
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte("_vfsdata.go")) {
+		t.Error("output file embedded itself")
+	}
+}